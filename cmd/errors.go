@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"errors"
+	"syscall"
+
+	"github.com/thestuckster/readmerunner/rr"
+)
+
+// Process exit codes. These are part of the CLI's interface, see the README.
+const (
+	ExitOK             = 0 // everything ran (or there was nothing to run)
+	ExitError          = 1 // unexpected error, e.g. invalid flags or unreadable input
+	ExitInvalidArgs    = 2 // invalid block selection
+	ExitProjectPath    = 3 // the project path can't be resolved or doesn't exist
+	ExitReadmeNotFound = 4 // no readme in the project directory, or it can't be read
+	ExitBlockFailed    = 5 // a block failed
+	ExitInvalidReadme  = 6 // the blocks can't be run as written, e.g. their dependencies form a cycle or an attribute is invalid
+
+	// ExitInterrupted is the exit code after Ctrl-C. Other signals exit with 128 plus the signal
+	// number, like shells do, e.g. 143 for SIGTERM.
+	ExitInterrupted = 130
+)
+
+// exitCode maps an error returned by a command to the process exit code
+func exitCode(err error) int {
+	var pathErr *rr.ProjectPathError
+	var readmeErr *rr.ReadmeNotFoundError
+	var selectionErr *rr.InvalidSelectionError
+	var blockErr *rr.BlockFailedError
+	var dependencyErr *rr.DependencyError
+	var cycleErr *rr.CycleError
+	var attributeErr *rr.AttributeError
+	var interruptedErr *rr.InterruptedError
+
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.As(err, &interruptedErr):
+		if sig, ok := interruptedErr.Signal.(syscall.Signal); ok {
+			return 128 + int(sig)
+		}
+		return ExitInterrupted
+	case errors.As(err, &blockErr):
+		return ExitBlockFailed
+	case errors.As(err, &selectionErr):
+		return ExitInvalidArgs
+	case errors.As(err, &pathErr):
+		return ExitProjectPath
+	case errors.As(err, &readmeErr):
+		return ExitReadmeNotFound
+	case errors.As(err, &dependencyErr), errors.As(err, &cycleErr), errors.As(err, &attributeErr):
+		return ExitInvalidReadme
+	default:
+		return ExitError
+	}
+}
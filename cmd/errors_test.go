@@ -0,0 +1,38 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/thestuckster/readmerunner/rr"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		err      error
+		expected int
+	}{
+		{nil, ExitOK},
+		{errors.New("boom"), ExitError},
+		{&rr.InvalidSelectionError{Err: errors.New("bad range")}, ExitInvalidArgs},
+		{&rr.ProjectPathError{Path: "/missing"}, ExitProjectPath},
+		{&rr.ReadmeNotFoundError{Dir: "/project"}, ExitReadmeNotFound},
+		{&rr.BlockFailedError{Index: 1, Err: errors.New("failed")}, ExitBlockFailed},
+		{fmt.Errorf("wrapped: %w", &rr.BlockFailedError{Index: 2}), ExitBlockFailed},
+		{&rr.DependencyError{Index: 2, Need: "Build"}, ExitInvalidReadme},
+		{&rr.CycleError{Blocks: []string{"A", "B", "A"}}, ExitInvalidReadme},
+		{&rr.AttributeError{Index: 2, Attribute: "timeout", Value: "1"}, ExitInvalidReadme},
+		{&rr.RunFailedError{Failures: []*rr.BlockFailedError{{Index: 1}, {Index: 3}}}, ExitBlockFailed},
+		{&rr.InterruptedError{Signal: os.Interrupt}, ExitInterrupted},
+		{&rr.InterruptedError{Signal: syscall.SIGTERM}, 143},
+	}
+
+	for _, tt := range tests {
+		if code := exitCode(tt.err); code != tt.expected {
+			t.Errorf("exitCode(%v) = %d, expected %d", tt.err, code, tt.expected)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/thestuckster/readmerunner/rr"
+)
+
+// listCmd represents the list command
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "Lists RR blocks",
+	Long:  `Lists every RR block found in your readme file along with where it is defined and whether it has been approved.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := list(cmd); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+
+	listCmd.Flags().StringP("path", "p", "", "Full path to the project directory containing the README file")
+	listCmd.Flags().StringP("env", "e", "", "Path to .env file (if not provided, looks for .env in project directory)")
+	listCmd.Flags().Bool("json", false, "Print the blocks as JSON")
+}
+
+// blockSummary describes a single RR block for the list command
+type blockSummary struct {
+	Index     int           `json:"index"`
+	Name      string        `json:"name"`
+	Line      int           `json:"line"`
+	Commands  int           `json:"commands"`
+	Variables []string      `json:"variables"`
+	Prompts   []string      `json:"prompts"`
+	Uses      []variableUse `json:"uses"`
+	Tags      []string      `json:"tags"`
+	Needs     []string      `json:"needs"`
+	Approved  bool          `json:"approved"`
+	Hash      string        `json:"hash"`
+}
+
+// variableUse is a variable a block refers to without defining it, and where its value comes from:
+// global (RR-VARS), env (the .env file), captured (by another block) or undefined
+type variableUse struct {
+	Name   string `json:"name"`
+	Source string `json:"source"`
+}
+
+// variableLayers are the variables a block can use besides its own
+type variableLayers struct {
+	globals  map[string]string // from RR-VARS
+	env      map[string]string // from the .env file
+	captured map[string]bool   // captured by any block
+}
+
+// source tells which layer a variable that a block doesn't define comes from. At run time values
+// captured by other blocks override globals, which override the .env file.
+func (l variableLayers) source(varName string) string {
+	if l.captured[varName] {
+		return "captured"
+	}
+	if _, exists := l.globals[varName]; exists {
+		return "global"
+	}
+	if _, exists := l.env[varName]; exists {
+		return "env"
+	}
+	return "undefined"
+}
+
+// list prints a summary of every block in the project's readme
+func list(cmd *cobra.Command) error {
+	workDir, doc, err := loadProjectBlocks(cmd)
+	if err != nil {
+		return err
+	}
+	blocks := doc.Blocks
+	approvedHashes := rr.NewApprovalStore(workDir).Approved()
+
+	envPath, _ := cmd.Flags().GetString("env")
+	layers := variableLayers{globals: doc.Globals, env: rr.LoadEnv(envPath, workDir), captured: make(map[string]bool)}
+	for _, block := range blocks {
+		for _, command := range block.Commands {
+			if varName, _, isCapture := rr.ParseCapture(command); isCapture {
+				layers.captured[varName] = true
+			}
+		}
+	}
+
+	summaries := make([]blockSummary, 0, len(blocks))
+	for i, block := range blocks {
+		summaries = append(summaries, summarizeBlock(i+1, block, layers, approvedHashes))
+	}
+
+	asJSON, _ := cmd.Flags().GetBool("json")
+	if asJSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(summaries); err != nil {
+			return fmt.Errorf("error encoding blocks: %w", err)
+		}
+		return nil
+	}
+
+	if len(summaries) == 0 {
+		fmt.Println("No RR blocks found in readme file")
+		return nil
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "#\tLINE\tNAME\tCOMMANDS\tVARIABLES\tPROMPTS\tUSES\tTAGS\tNEEDS\tAPPROVED")
+	for _, summary := range summaries {
+		name := summary.Name
+		if name == "" {
+			name = "(unnamed)"
+		}
+		approved := "no"
+		if summary.Approved {
+			approved = "yes"
+		}
+		var uses []string
+		for _, use := range summary.Uses {
+			uses = append(uses, use.Name+"("+use.Source+")")
+		}
+		fmt.Fprintf(writer, "%d\t%d\t%s\t%d\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			summary.Index, summary.Line, name, summary.Commands,
+			joinOrDash(summary.Variables), joinOrDash(summary.Prompts), joinOrDash(uses),
+			joinOrDash(summary.Tags), joinOrDash(summary.Needs), approved)
+	}
+	return writer.Flush()
+}
+
+// summarizeBlock collects the details the list command shows for a block
+func summarizeBlock(index int, block rr.Block, layers variableLayers, approvedHashes map[string]bool) blockSummary {
+	hash := rr.HashBlockWithGlobals(block, layers.globals)
+	summary := blockSummary{
+		Index:     index,
+		Name:      block.Name,
+		Line:      block.Line,
+		Commands:  len(block.Commands),
+		Variables: []string{},
+		Prompts:   []string{},
+		Uses:      []variableUse{},
+		Tags:      []string{},
+		Needs:     []string{},
+		Approved:  approvedHashes[hash],
+		Hash:      hash,
+	}
+
+	for varName, varValue := range block.Variables {
+		if strings.HasPrefix(varValue, "#PROMPT:") {
+			summary.Prompts = append(summary.Prompts, varName)
+		} else {
+			summary.Variables = append(summary.Variables, varName)
+		}
+	}
+	// Captured variables are defined by the block too
+	defined := make(map[string]bool)
+	for _, cmd := range block.Commands {
+		if varName, _, isCapture := rr.ParseCapture(cmd); isCapture {
+			defined[varName] = true
+			if _, exists := block.Variables[varName]; !exists {
+				summary.Variables = append(summary.Variables, varName)
+			}
+		}
+	}
+	for _, varName := range rr.VariableReferences(block) {
+		if _, exists := block.Variables[varName]; !exists && !defined[varName] {
+			summary.Uses = append(summary.Uses, variableUse{Name: varName, Source: layers.source(varName)})
+		}
+	}
+	sort.Strings(summary.Variables)
+	sort.Strings(summary.Prompts)
+	summary.Tags = append(summary.Tags, block.Tags...)
+	summary.Needs = append(summary.Needs, block.Needs...)
+
+	return summary
+}
+
+// joinOrDash joins values with commas, or returns "-" when there are none
+func joinOrDash(values []string) string {
+	if len(values) == 0 {
+		return "-"
+	}
+	return strings.Join(values, ",")
+}
@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/thestuckster/readmerunner/rr"
+)
+
+func TestSummarizeBlock(t *testing.T) {
+	block := rr.Block{
+		Name: "Deploy",
+		Line: 12,
+		Tags: []string{"deploy"},
+		Variables: map[string]string{
+			"env":      "staging",
+			"password": "#PROMPT:Enter password:",
+			"app":      "api",
+		},
+		Commands: []string{"echo #env", "deploy.sh #app #password"},
+	}
+	approved := map[string]bool{rr.HashBlock(block): true}
+
+	summary := summarizeBlock(4, block, variableLayers{}, approved)
+
+	if summary.Index != 4 || summary.Line != 12 || summary.Name != "Deploy" {
+		t.Errorf("Unexpected summary header: %+v", summary)
+	}
+	if summary.Commands != 2 {
+		t.Errorf("Expected 2 commands, got %d", summary.Commands)
+	}
+	if len(summary.Variables) != 2 || summary.Variables[0] != "app" || summary.Variables[1] != "env" {
+		t.Errorf("Expected sorted variables [app env], got %v", summary.Variables)
+	}
+	if len(summary.Prompts) != 1 || summary.Prompts[0] != "password" {
+		t.Errorf("Expected prompts [password], got %v", summary.Prompts)
+	}
+	if !summary.Approved {
+		t.Error("Expected block to be reported as approved")
+	}
+}
+
+func TestSummarizeBlock_NotApproved(t *testing.T) {
+	block := rr.Block{Commands: []string{"echo test"}}
+
+	summary := summarizeBlock(1, block, variableLayers{}, map[string]bool{})
+
+	if summary.Approved {
+		t.Error("Expected block not to be approved")
+	}
+	if summary.Variables == nil || summary.Prompts == nil || summary.Uses == nil || summary.Tags == nil || summary.Needs == nil {
+		t.Error("Expected empty slices rather than nil so JSON output uses []")
+	}
+}
+
+func TestSummarizeBlock_Uses(t *testing.T) {
+	block := rr.Block{
+		Variables:  map[string]string{"app": "api"},
+		Attributes: map[string]string{"cwd": "#project-dir"},
+		Commands: []string{
+			"curl #api-url/#app",
+			"TOKEN = #capture(login #user)",
+			"deploy #TOKEN #API_KEY #release #missing",
+		},
+	}
+	layers := variableLayers{
+		globals:  map[string]string{"api-url": "http://localhost", "project-dir": "web"},
+		env:      map[string]string{"API_KEY": "secret", "user": "admin"},
+		captured: map[string]bool{"TOKEN": true, "release": true},
+	}
+
+	summary := summarizeBlock(1, block, layers, map[string]bool{})
+
+	expected := []variableUse{
+		{"api-url", "global"}, {"user", "env"}, {"API_KEY", "env"}, {"release", "captured"},
+		{"missing", "undefined"}, {"project-dir", "global"},
+	}
+	if len(summary.Uses) != len(expected) {
+		t.Fatalf("Expected uses %v, got %v", expected, summary.Uses)
+	}
+	for i := range expected {
+		if summary.Uses[i] != expected[i] {
+			t.Errorf("Expected uses %v, got %v", expected, summary.Uses)
+			break
+		}
+	}
+}
+
+func TestList_FlagsDefined(t *testing.T) {
+	if listCmd.Flags().Lookup("path") == nil {
+		t.Error("Expected 'path' flag to be defined")
+	}
+	if listCmd.Flags().Lookup("env") == nil {
+		t.Error("Expected 'env' flag to be defined")
+	}
+	if listCmd.Flags().Lookup("json") == nil {
+		t.Error("Expected 'json' flag to be defined")
+	}
+}
@@ -1,551 +1,198 @@
 package cmd
 
 import (
-	"bufio"
-	"crypto/sha256"
-	"encoding/hex"
+	"context"
 	"fmt"
 	"os"
-	"os/exec"
-	"path/filepath"
-	"regexp"
-	"strings"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/thestuckster/readmerunner/rr"
 )
 
 // runCmd represents the run command
-var runCmd = &cobra.Command{
-	Use:   "run",
-	Short: "Runs commands",
-	Long:  `Runs the commands defined in your readme file in the order they appear.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		execute(cmd, args)
-	},
-}
-
-func init() {
-	rootCmd.AddCommand(runCmd)
-
-	runCmd.Flags().StringP("path", "p", "", "Full path to the project directory containing the README file")
-	runCmd.Flags().BoolP("trust", "t", false, "Auto-trust all blocks and skip confirmation prompts")
-	runCmd.Flags().StringP("env", "e", "", "Path to .env file (if not provided, looks for .env in project directory)")
-}
-
-// RRBlock represents a parsed ReadMe Runner block
-type RRBlock struct {
-	Name      string
-	Variables map[string]string
-	Commands  []string
-}
-
-func execute(cmd *cobra.Command, args []string) {
-	var workDir string
-	var err error
-
-	//use provided path if set.
-	projectPath, _ := cmd.Flags().GetString("path")
-	if projectPath != "" {
-		workDir, err = filepath.Abs(projectPath)
-		if err != nil {
-			fmt.Printf("Error resolving project path: %v\n", err)
-			os.Exit(-1)
-		}
-
-		if _, err := os.Stat(workDir); os.IsNotExist(err) {
-			fmt.Printf("Project path does not exist: %s\n", workDir)
-			os.Exit(-1)
-		}
-	} else {
-		workDir, err = os.Getwd()
-		if err != nil {
-			panic(err)
-		}
-	}
-
-	readmePath, exists := findReadme(workDir)
-	if !exists {
-		fmt.Printf("No readme found in directory %s\n", workDir)
-		os.Exit(-1)
-	}
-
-	content, err := os.ReadFile(readmePath)
-	if err != nil {
-		fmt.Printf("Error reading readme file: %v\n", err)
-		os.Exit(-1)
-	}
-
-	blocks := parseRRBlocks(string(content))
-	if len(blocks) == 0 {
-		fmt.Println("No RR blocks found in readme file")
-		return
-	}
-
-	// Load environment variables from .env file
-	envVars := loadEnvVars(cmd, workDir)
-
-	trust, _ := cmd.Flags().GetBool("trust")
-
-	var approvedHashes map[string]bool
-	if !trust {
-		approvedHashes = loadApprovedHashes(workDir)
-	}
-
-	for i, block := range blocks {
-		// If trust flag is set, skip all hash operations and execute directly
-		if trust {
-			if err := executeBlock(block, envVars); err != nil {
-				fmt.Printf("Error executing block %s: %v\n", block.Name, err)
-				os.Exit(-1)
-			}
-			continue
-		}
-
-		// Check hash and prompt if not approved
-		blockHash := hashBlock(block)
-		isApproved := approvedHashes[blockHash]
-
-		if !isApproved {
-			if !promptForBlock(block, i+1, len(blocks)) {
-				fmt.Println("Skipping block...")
-				continue
+var runCmd = newRunCmd()
+
+// newRunCmd creates the run command with all of its flags
+func newRunCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "run [block numbers or ranges...]",
+		Short: "Runs commands",
+		Long: `Runs the commands defined in your readme file in the order they appear.
+
+Blocks can be selected by number or range (e.g. "run 3" or "run 3-5"), by name with
+--only and --skip (glob patterns such as "Docker*") and by tag with --tag.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := execute(cmd, args); err != nil {
+				// With --output json stdout only carries events
+				out := os.Stdout
+				if output, _ := cmd.Flags().GetString("output"); output == "json" {
+					out = os.Stderr
+				}
+				fmt.Fprintf(out, "Error: %v\n", err)
+				os.Exit(exitCode(err))
 			}
-			saveBlockHash(workDir, blockHash)
-		}
-
-		if err := executeBlock(block, envVars); err != nil {
-			fmt.Printf("Error executing block %s: %v\n", block.Name, err)
-			os.Exit(-1)
-		}
-	}
-}
-
-func findReadme(workDir string) (string, bool) {
-	readmePaths := []string{
-		filepath.Join(workDir, "readme.md"),
-		filepath.Join(workDir, "README.md"),
+		},
 	}
 
-	for _, path := range readmePaths {
-		if _, err := os.Stat(path); err == nil {
-			return path, true
-		}
-	}
+	addSelectionFlags(cmd)
+	cmd.Flags().BoolP("trust", "t", false, "Auto-trust all blocks and skip confirmation prompts")
+	cmd.Flags().Bool("dry-run", false, "Print the fully substituted commands without executing anything")
+	addExecutionFlags(cmd)
+	cmd.Flags().Bool("keep-going", false, "Continue with the remaining blocks when a block fails (overridden by the on-error attribute)")
+	cmd.Flags().Bool("resume", false, "Skip the blocks that completed in the last run and haven't changed since")
+	cmd.Flags().String("output", "text", "Output format: text, or json for a stream of events on stdout, one JSON object per line")
 
-	return "", false
+	return cmd
 }
 
-// findEnvFile finds the .env file in the specified directory or returns the provided path
-func findEnvFile(envPath string, workDir string) (string, bool) {
-	// If env path is provided, use it directly
-	if envPath != "" {
-		absPath, err := filepath.Abs(envPath)
-		if err != nil {
-			return "", false
-		}
-		if _, err := os.Stat(absPath); err == nil {
-			return absPath, true
-		}
-		return "", false
-	}
-
-	envFilePath := filepath.Join(workDir, ".env")
-	if _, err := os.Stat(envFilePath); err == nil {
-		return envFilePath, true
-	}
-
-	return "", false
+func init() {
+	rootCmd.AddCommand(runCmd)
 }
 
-// loadEnvVars loads environment variables from .env file
-func loadEnvVars(cmd *cobra.Command, workDir string) map[string]string {
-	envVars := make(map[string]string)
-
-	envPath, _ := cmd.Flags().GetString("env")
-
-	envFilePath, exists := findEnvFile(envPath, workDir)
-	if !exists {
-		return envVars // Return empty map if no .env file found
-	}
-
-	content, err := os.ReadFile(envFilePath)
-	if err != nil {
-		// If we can't read the env file, return an empty map. 
-		return envVars
-	}
-
-	lines := strings.Split(string(content), "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-
-		// Skip empty lines and comments
-		if line == "" || strings.HasPrefix(line, "#") {
-			continue
-		}
-
-		// Parse KEY=VALUE format
-		parts := strings.SplitN(line, "=", 2)
-		if len(parts) == 2 {
-			key := strings.TrimSpace(parts[0])
-			value := strings.TrimSpace(parts[1])
-
-			// Remove quotes
-			if len(value) >= 2 {
-				if (strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`)) ||
-					(strings.HasPrefix(value, `'`) && strings.HasSuffix(value, `'`)) {
-					value = value[1 : len(value)-1]
-				}
-			}
-
-			envVars[key] = value
-		}
-	}
-
-	return envVars
+// addSelectionFlags defines the flags that find the readme and select its blocks
+func addSelectionFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("path", "p", "", "Full path to the project directory containing the README file")
+	cmd.Flags().StringP("env", "e", "", "Path to .env file (if not provided, looks for .env in project directory)")
+	cmd.Flags().StringArray("only", nil, "Only run blocks whose name matches this glob pattern (repeatable)")
+	cmd.Flags().StringArray("skip", nil, "Skip blocks whose name matches this glob pattern (repeatable)")
+	cmd.Flags().StringArray("tag", nil, "Only run blocks with this tag (repeatable)")
 }
 
-// hashBlock creates a SHA256 hash of the block content
-// The hash includes block name, commands, and variables to uniquely identify the block
-func hashBlock(block RRBlock) string {
-	var content strings.Builder
-	content.WriteString("name:" + block.Name + "\n")
-
-	var varKeys []string
-	for k := range block.Variables {
-		varKeys = append(varKeys, k)
-	}
-
-	for i := 0; i < len(varKeys)-1; i++ {
-		for j := i + 1; j < len(varKeys); j++ {
-			if varKeys[i] > varKeys[j] {
-				varKeys[i], varKeys[j] = varKeys[j], varKeys[i]
-			}
-		}
-	}
-	for _, k := range varKeys {
-		content.WriteString("var:" + k + "=" + block.Variables[k] + "\n")
-	}
-
-	for _, cmd := range block.Commands {
-		content.WriteString("cmd:" + cmd + "\n")
-	}
-
-	hash := sha256.Sum256([]byte(content.String()))
-	return hex.EncodeToString(hash[:])
+// addExecutionFlags defines the flags that control how the commands of a block run
+func addExecutionFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("export-vars", false, "Export .env and RR variables into the environment of executed commands")
+	cmd.Flags().Bool("clean-env", false, "Run commands with an empty environment, keeping only the variables from --inherit-env")
+	cmd.Flags().StringSlice("inherit-env", nil, "Variables kept from the parent environment with --clean-env (default PATH,HOME,USER,SHELL,TERM,LANG,TMPDIR)")
+	cmd.Flags().Duration("timeout", 0, "Fail a block that runs longer than this, e.g. 5m (overridden by the timeout attribute)")
+	cmd.Flags().Duration("command-timeout", 0, "Fail a block when one of its commands runs longer than this (overridden by the command-timeout attribute)")
+	cmd.Flags().Int("retries", 0, "Run failed commands again up to this many times (overridden by the retries attribute)")
+	cmd.Flags().Duration("backoff", time.Second, "Wait before the first retry, doubled for every further retry (overridden by the backoff attribute)")
+	cmd.Flags().Int("parallel", 1, "Run up to this many blocks of the same group at the same time")
+	cmd.Flags().Bool("logs", false, "Save each block's output and a manifest of the run in .rr-runs/<timestamp> in the project directory")
 }
 
-// loadApprovedHashes reads the .rr file and returns a map of approved block hashes
-func loadApprovedHashes(workDir string) map[string]bool {
-	rrFilePath := filepath.Join(workDir, ".rr")
-	approvedHashes := make(map[string]bool)
-
-	if _, err := os.Stat(rrFilePath); os.IsNotExist(err) {
-		return approvedHashes
-	}
-
-	content, err := os.ReadFile(rrFilePath)
+// execute runs the selected blocks of the project's readme. It stops at the first block that fails,
+// unless --keep-going or the block's on-error attribute says otherwise. Cleanup blocks run in any case,
+// even when the run is interrupted.
+func execute(cmd *cobra.Command, args []string) error {
+	// With JSON output, stdout is kept for the events and everything else goes to stderr
+	output, _ := cmd.Flags().GetString("output")
+	messages := os.Stdout
+	switch output {
+	case "text":
+	case "json":
+		messages = os.Stderr
+	default:
+		return fmt.Errorf("invalid output format %q, use text or json", output)
+	}
+
+	workDir, doc, err := loadProjectBlocks(cmd)
 	if err != nil {
-		//don't crash if we can't read the file.
-		return approvedHashes
+		return err
 	}
-
-	lines := strings.Split(string(content), "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line != "" {
-			approvedHashes[line] = true
-		}
-	}
-
-	return approvedHashes
-}
-
-// saveBlockHash appends a block hash to the .rr file
-func saveBlockHash(workDir string, hash string) {
-	rrFilePath := filepath.Join(workDir, ".rr")
-
-	approvedHashes := loadApprovedHashes(workDir)
-	if approvedHashes[hash] {
-		return
+	if len(doc.Blocks) == 0 {
+		fmt.Fprintln(messages, "No RR blocks found in readme file")
+		return nil
 	}
 
-	file, err := os.OpenFile(rrFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	opts, err := runOptions(cmd, args, workDir)
 	if err != nil {
-		//don't crash if we can't write to the file.
-		return
+		return err
 	}
-	defer file.Close()
-
-	if _, err := file.WriteString(hash + "\n"); err != nil {
-		return
+	opts.Approvals = rr.NewApprovalStore(workDir)
+	opts.Checkpoints = rr.NewCheckpointStore(workDir)
+	opts.Trust, _ = cmd.Flags().GetBool("trust")
+	opts.KeepGoing, _ = cmd.Flags().GetBool("keep-going")
+	opts.Resume, _ = cmd.Flags().GetBool("resume")
+	if output == "json" {
+		opts.Stdout = os.Stderr
+		opts.Events = rr.JSONEvents(os.Stdout)
 	}
-}
-
-// parseRRBlocks extracts all RR blocks from the readme content
-func parseRRBlocks(content string) []RRBlock {
-	var blocks []RRBlock
-
-	// Regex to match HTML comments that start with RR
-	// Matches: <!-- RR --> or <!-- RR[BlockName] -->
-	rrBlockRegex := regexp.MustCompile(`<!--\s*RR(\[([^\]]+)\])?\s*`)
-
-	lines := strings.Split(content, "\n")
-	inBlock := false
-	var currentBlock *RRBlock
-	var blockLines []string
-
-	for _, line := range lines {
-		// Check if this line starts an RR block
-		if rrBlockRegex.MatchString(line) {
-			if inBlock {
-				// Close previous block if we encounter a new one
-				if currentBlock != nil {
-					processBlockContent(currentBlock, blockLines)
-					blocks = append(blocks, *currentBlock)
-				}
-			}
-
-			// Extract block name
-			matches := rrBlockRegex.FindStringSubmatch(line)
-			blockName := ""
-			if len(matches) > 2 && matches[2] != "" {
-				blockName = matches[2]
-			}
-
-			currentBlock = &RRBlock{
-				Name:      blockName,
-				Variables: make(map[string]string),
-				Commands:  []string{},
-			}
-			blockLines = []string{}
-			inBlock = true
-			continue
-		}
-
-		// Check if this line ends the block
-		if inBlock && strings.Contains(line, "-->") {
-			// Remove the closing --> from the last line
-			lastLine := strings.TrimSuffix(strings.TrimSpace(line), "-->")
-			if lastLine != "" {
-				blockLines = append(blockLines, lastLine)
-			}
-
-			processBlockContent(currentBlock, blockLines)
-			blocks = append(blocks, *currentBlock)
-			inBlock = false
-			currentBlock = nil
-			blockLines = nil
-			continue
-		}
 
-		if inBlock {
-			blockLines = append(blockLines, line)
-		}
-		// If not inBlock, the line is outside any RR block and is ignored
-	}
+	runner := rr.NewRunner(opts)
 
-	// Handle case where block doesn't close properly
-	if inBlock && currentBlock != nil {
-		processBlockContent(currentBlock, blockLines)
-		blocks = append(blocks, *currentBlock)
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	if dryRun {
+		return runner.DryRun(doc)
 	}
 
-	return blocks
+	return runInterruptible(cmd, runner, doc)
 }
 
-// processBlockContent processes the content of an RR block to extract variables, prompts, and commands
-func processBlockContent(block *RRBlock, lines []string) {
-	var currentCommand strings.Builder
-	var commands []string
-
-	varAssignRegex := regexp.MustCompile(`^\s*([a-zA-Z0-9_-]+)\s*=\s*"([^"]+)"\s*$`)
-	promptRegex := regexp.MustCompile(`^\s*([a-zA-Z0-9_-]+)\s*=\s*#prompt\("([^"]+)"\)\s*$`)
-
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
-		}
-
-		// Check for variable assignment
-		if matches := varAssignRegex.FindStringSubmatch(line); matches != nil {
-			// Save any pending command before processing variable
-			if currentCommand.Len() > 0 {
-				cmd := strings.TrimSpace(currentCommand.String())
-				if cmd != "" {
-					commands = append(commands, cmd)
-				}
-				currentCommand.Reset()
-			}
-			block.Variables[matches[1]] = matches[2]
-			continue
-		}
-
-		// Check for prompt assignment
-		if matches := promptRegex.FindStringSubmatch(line); matches != nil {
-			// Save any pending command before processing prompt
-			if currentCommand.Len() > 0 {
-				cmd := strings.TrimSpace(currentCommand.String())
-				if cmd != "" {
-					commands = append(commands, cmd)
-				}
-				currentCommand.Reset()
-			}
-			// Prompt will be handled during execution
-			block.Variables[matches[1]] = "#PROMPT:" + matches[2]
-			continue
-		}
-
-		// This is a command line
-		if currentCommand.Len() > 0 {
-			currentCommand.WriteString(" ")
-		}
-
-		// Handle multi-line commands (backslash continuation)
-		trimmedLine := strings.TrimRight(line, " \t")
-		if strings.HasSuffix(trimmedLine, "\\") {
-			currentCommand.WriteString(strings.TrimSuffix(trimmedLine, "\\"))
-			continue //next line
-		}
-
-		// Add the line to current command
-		currentCommand.WriteString(line)
-
-		// If no backslash, this command is complete
-		cmd := strings.TrimSpace(currentCommand.String())
-		if cmd != "" {
-			commands = append(commands, cmd)
-		}
-		currentCommand.Reset()
+// runOptions builds the options of a run from the selection and execution flags
+func runOptions(cmd *cobra.Command, args []string, workDir string) (rr.Options, error) {
+	only, _ := cmd.Flags().GetStringArray("only")
+	skip, _ := cmd.Flags().GetStringArray("skip")
+	tags, _ := cmd.Flags().GetStringArray("tag")
+	filter, err := rr.NewFilter(args, only, skip, tags)
+	if err != nil {
+		return rr.Options{}, err
 	}
 
-	// Add any remaining command
-	if currentCommand.Len() > 0 {
-		cmd := strings.TrimSpace(currentCommand.String())
-		if cmd != "" {
-			commands = append(commands, cmd)
-		}
-	}
+	// Load environment variables from .env file
+	envPath, _ := cmd.Flags().GetString("env")
 
-	block.Commands = commands
+	opts := rr.Options{
+		Dir:     workDir,
+		Filter:  filter,
+		EnvVars: rr.LoadEnv(envPath, workDir),
+	}
+	opts.ExportVars, _ = cmd.Flags().GetBool("export-vars")
+	opts.CleanEnv, _ = cmd.Flags().GetBool("clean-env")
+	opts.InheritEnv, _ = cmd.Flags().GetStringSlice("inherit-env")
+	opts.Timeout, _ = cmd.Flags().GetDuration("timeout")
+	opts.CommandTimeout, _ = cmd.Flags().GetDuration("command-timeout")
+	opts.Retries, _ = cmd.Flags().GetInt("retries")
+	opts.Backoff, _ = cmd.Flags().GetDuration("backoff")
+	opts.Parallel, _ = cmd.Flags().GetInt("parallel")
+	if logs, _ := cmd.Flags().GetBool("logs"); logs {
+		opts.Logs = rr.NewRunLogs(workDir)
+	}
+	return opts, nil
 }
 
-// promptForBlock prompts the user for confirmation before executing a block
-// Returns true if user confirms with "y", false otherwise
-func promptForBlock(block RRBlock, blockNum, totalBlocks int) bool {
-	reader := bufio.NewReader(os.Stdin)
-
-	fmt.Printf("\n--- Block %d of %d ---\n", blockNum, totalBlocks)
-	if block.Name != "" {
-		fmt.Printf("Block Name: %s\n", block.Name)
-	} else {
-		// Show first command as identifier if no name
-		if len(block.Commands) > 0 {
-			firstCmd := block.Commands[0]
-			if len(firstCmd) > 50 {
-				firstCmd = firstCmd[:50] + "..."
-			}
-			fmt.Printf("Command: %s\n", firstCmd)
-		}
-	}
-
-	// Show commands that will be executed
-	if len(block.Commands) > 0 {
-		fmt.Println("Commands to execute:")
-		for i, cmd := range block.Commands {
-			fmt.Printf("  %d. %s\n", i+1, cmd)
-		}
-	}
-
-	// Show variables if any
-	if len(block.Variables) > 0 {
-		fmt.Println("Variables:")
-		for varName, varValue := range block.Variables {
-			if strings.HasPrefix(varValue, "#PROMPT:") {
-				fmt.Printf("  %s = #prompt(\"%s\")\n", varName, strings.TrimPrefix(varValue, "#PROMPT:"))
-			} else {
-				fmt.Printf("  %s = \"%s\"\n", varName, varValue)
+// runInterruptible runs the document with the runner. Ctrl-C is passed on to the running commands,
+// and cleanup blocks still get to run before we exit. A second Ctrl-C kills whatever is still running.
+func runInterruptible(cmd *cobra.Command, runner *rr.Runner, doc rr.Document) error {
+	ctx := cmd.Context()
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				runner.Interrupt(sig)
+			case <-done:
+				return
 			}
 		}
-	}
+	}()
 
-	// Prompt for confirmation
-	fmt.Print("\nExecute this block? (y/n): ")
-	input, err := reader.ReadString('\n')
-	if err != nil {
-		fmt.Printf("\nError reading input: %v\n", err)
-		return false
-	}
-
-	fmt.Println() //ensure next prompt appears on new line
-
-	response := strings.TrimSpace(strings.ToLower(input))
-	return response == "y" || response == "yes"
+	return runner.Run(ctx, doc)
 }
 
-// executeBlock executes a single RR block
-func executeBlock(block RRBlock, envVars map[string]string) error {
-	// First, handle prompts and populate variables
-	reader := bufio.NewReader(os.Stdin)
-	for varName, varValue := range block.Variables {
-		if strings.HasPrefix(varValue, "#PROMPT:") {
-			question := strings.TrimPrefix(varValue, "#PROMPT:")
-			// Ensure prompt appears on a new line
-			fmt.Printf("\n%s ", question)
-			input, err := reader.ReadString('\n')
-			if err != nil {
-				return fmt.Errorf("error reading input for prompt: %v", err)
-			}
-			block.Variables[varName] = strings.TrimSpace(input)
-		}
+// loadProjectBlocks resolves the project directory from the --path flag (or the current directory)
+// and parses the RR blocks of its readme
+func loadProjectBlocks(cmd *cobra.Command) (string, rr.Document, error) {
+	projectPath, _ := cmd.Flags().GetString("path")
+	workDir, err := rr.ResolveProjectDir(projectPath)
+	if err != nil {
+		return "", rr.Document{}, err
 	}
 
-	// Execute each command
-	for _, cmd := range block.Commands {
-		// Replace variable references in command
-		// Block variables take precedence over env variables
-		mergedVars := make(map[string]string)
-		// First add env variables
-		for k, v := range envVars {
-			mergedVars[k] = v
-		}
-		// Then add block variables (they override env variables)
-		for k, v := range block.Variables {
-			mergedVars[k] = v
-		}
-		// Substitute variables (block vars override env vars)
-		cmd = substituteVariables(cmd, mergedVars)
-
-		// Display block name or command for confirmation
-		if block.Name != "" {
-			fmt.Printf("\n[%s]\nExecuting: %s\nOutput:\n", block.Name, cmd)
-		} else {
-			fmt.Printf("\nExecuting: %s\nOutput:\n", cmd)
-		}
-
-		// Execute the command
-		shellCmd := exec.Command("sh", "-c", cmd)
-		shellCmd.Stdout = os.Stdout
-		shellCmd.Stderr = os.Stderr
-		shellCmd.Stdin = os.Stdin
-
-		if err := shellCmd.Run(); err != nil {
-			return fmt.Errorf("command failed: %v", err)
-		}
+	doc, err := rr.LoadProject(workDir)
+	if err != nil {
+		return "", rr.Document{}, err
 	}
 
-	return nil
-}
-
-// substituteVariables replaces variable references (#var-name) with their values
-func substituteVariables(cmd string, variables map[string]string) string {
-	varUsageRegex := regexp.MustCompile(`#([a-zA-Z0-9_-]+)`)
-
-	return varUsageRegex.ReplaceAllStringFunc(cmd, func(match string) string {
-		varName := strings.TrimPrefix(match, "#")
-		if value, exists := variables[varName]; exists {
-			return value
-		}
-		return match
-	})
+	return workDir, doc, nil
 }
@@ -0,0 +1,156 @@
+//go:build linux
+
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+// TestTerminalHelper runs readmerunner with the arguments in RR_TERMINAL_ARGS when the test binary
+// is started by runInTerminal
+func TestTerminalHelper(t *testing.T) {
+	args := os.Getenv("RR_TERMINAL_ARGS")
+	if args == "" {
+		t.Skip("only runs as the helper process of terminal tests")
+	}
+	rootCmd.SetArgs(strings.Split(args, "\n"))
+	rootCmd.Execute()
+	os.Exit(0)
+}
+
+// terminalOutput collects what is written to the terminal
+type terminalOutput struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (o *terminalOutput) String() string {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.buf.String()
+}
+
+// openTerminal opens a new pseudo terminal, returning its controlling and its terminal end
+func openTerminal(t *testing.T) (*os.File, *os.File) {
+	t.Helper()
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR, 0)
+	if err != nil {
+		t.Skipf("No pseudo terminals available: %v", err)
+	}
+	unlock := int32(0)
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, master.Fd(), syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); errno != 0 {
+		t.Fatalf("Failed to unlock the terminal: %v", errno)
+	}
+	var n uint32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, master.Fd(), syscall.TIOCGPTN, uintptr(unsafe.Pointer(&n))); errno != 0 {
+		t.Fatalf("Failed to get the terminal's number: %v", errno)
+	}
+	tty, err := os.OpenFile("/dev/pts/"+strconv.Itoa(int(n)), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		t.Fatalf("Failed to open the terminal: %v", err)
+	}
+	return master, tty
+}
+
+// runInTerminal starts readmerunner with the given arguments as the foreground process of a new
+// terminal, pressing Ctrl-C once for every time the output contains pressAfter
+func runInTerminal(t *testing.T, pressAfter string, presses int, args ...string) (int, string) {
+	t.Helper()
+	master, tty := openTerminal(t)
+	defer master.Close()
+
+	helper := exec.Command(os.Args[0], "-test.run=^TestTerminalHelper$")
+	helper.Env = append(os.Environ(), "RR_TERMINAL_ARGS="+strings.Join(args, "\n"))
+	helper.Stdin, helper.Stdout, helper.Stderr = tty, tty, tty
+	helper.SysProcAttr = &syscall.SysProcAttr{Setsid: true, Setctty: true}
+	if err := helper.Start(); err != nil {
+		t.Fatalf("Failed to start readmerunner: %v", err)
+	}
+	tty.Close()
+
+	var output terminalOutput
+	go func() {
+		buf := make([]byte, 4096)
+		for {
+			n, err := master.Read(buf)
+			output.mu.Lock()
+			output.buf.Write(buf[:n])
+			output.mu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	exited := make(chan error, 1)
+	go func() { exited <- helper.Wait() }()
+
+	for i := 1; i <= presses; i++ {
+		deadline := time.Now().Add(5 * time.Second)
+		for strings.Count(output.String(), pressAfter) < 1 && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		time.Sleep(300 * time.Millisecond)
+		master.Write([]byte{3}) // Ctrl-C
+	}
+
+	select {
+	case err := <-exited:
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode(), output.String()
+		}
+		return 0, output.String()
+	case <-time.After(10 * time.Second):
+		helper.Process.Kill()
+		t.Fatalf("Expected readmerunner to exit after Ctrl-C, got %q", output.String())
+		return 0, ""
+	}
+}
+
+func TestTerminal_CtrlCInterruptsRun(t *testing.T) {
+	tests := []struct {
+		name    string
+		command string
+		presses int
+	}{
+		{"command exits on SIGINT", "sleep 6", 1},
+		{"command handles SIGINT", "trap 'exit 0' INT; sleep 6", 1},
+		{"second Ctrl-C kills", "trap '' INT; sleep 6", 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workDir := t.TempDir()
+			next := filepath.Join(workDir, "next")
+			readme := "<!-- RR[Slow]\n" + tt.command + "\n-->\n<!-- RR[Next]\ntouch " + next + "\n-->\n"
+			if err := os.WriteFile(filepath.Join(workDir, "README.md"), []byte(readme), 0644); err != nil {
+				t.Fatalf("Failed to write README: %v", err)
+			}
+
+			start := time.Now()
+			code, output := runInTerminal(t, "Output:", tt.presses, "run", "--trust", "--path", workDir)
+
+			if code != ExitInterrupted {
+				t.Errorf("Expected exit code %d, got %d: %q", ExitInterrupted, code, output)
+			}
+			if time.Since(start) > 5*time.Second {
+				t.Errorf("Expected Ctrl-C to stop the command, took %s", time.Since(start))
+			}
+			if _, err := os.Stat(next); err == nil {
+				t.Error("Expected the next block not to run after Ctrl-C")
+			}
+		})
+	}
+}
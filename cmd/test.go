@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/thestuckster/readmerunner/rr"
+)
+
+// testCmd represents the test command
+var testCmd = newTestCmd()
+
+// newTestCmd creates the test command with all of its flags
+func newTestCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "test [block numbers or ranges...]",
+		Short: "Runs the test blocks of your readme as a test suite",
+		Long: `Runs the blocks of your readme marked with the test attribute, and the blocks they need, without
+asking for approval or input. Every block is a test case, and a failing block doesn't stop the others.
+
+The results can be written as a JUnit XML report with --junit and in the Test Anything Protocol with --tap,
+for CI systems to publish. Use - to write a report to stdout; the output of the blocks then goes to stderr.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			if err := executeTests(cmd, args); err != nil {
+				// Reports may be written to stdout, so errors go to stderr
+				fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+				os.Exit(exitCode(err))
+			}
+		},
+	}
+
+	addSelectionFlags(cmd)
+	addExecutionFlags(cmd)
+	cmd.Flags().String("junit", "", "Write a JUnit XML report to this file")
+	cmd.Flags().String("tap", "", "Write a TAP report to this file")
+
+	return cmd
+}
+
+func init() {
+	rootCmd.AddCommand(testCmd)
+}
+
+// executeTests runs the test blocks of the project's readme and writes the requested reports.
+// Reports are written even when tests fail, and the run's error decides the exit code.
+func executeTests(cmd *cobra.Command, args []string) error {
+	workDir, doc, err := loadProjectBlocks(cmd)
+	if err != nil {
+		return err
+	}
+
+	opts, err := runOptions(cmd, args, workDir)
+	if err != nil {
+		return err
+	}
+	opts.Filter = opts.Filter.Tests()
+	// Tests never wait for a person: blocks are trusted, prompts get no answer and failures don't stop the run
+	opts.Trust = true
+	opts.Stdin = strings.NewReader("")
+	opts.KeepGoing = true
+
+	junitPath, _ := cmd.Flags().GetString("junit")
+	tapPath, _ := cmd.Flags().GetString("tap")
+	if junitPath == "-" || tapPath == "-" {
+		opts.Stdout = os.Stderr
+	}
+
+	runner := rr.NewRunner(opts)
+	runErr := runInterruptible(cmd, runner, doc)
+	if len(runner.Results()) == 0 && runErr == nil {
+		fmt.Fprintln(os.Stderr, "No test blocks found in readme file")
+	}
+
+	suite := filepath.Base(workDir)
+	if junitPath != "" {
+		if err := writeReport(junitPath, func(w io.Writer) error { return rr.WriteJUnit(w, suite, runner.Results()) }); err != nil {
+			return fmt.Errorf("error writing the JUnit report: %w", err)
+		}
+	}
+	if tapPath != "" {
+		if err := writeReport(tapPath, func(w io.Writer) error { return rr.WriteTAP(w, runner.Results()) }); err != nil {
+			return fmt.Errorf("error writing the TAP report: %w", err)
+		}
+	}
+
+	return runErr
+}
+
+// writeReport writes a report to the file at path, or to stdout if path is -
+func writeReport(path string, write func(w io.Writer) error) error {
+	if path == "-" {
+		return write(os.Stdout)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// testTestCommand creates a fresh test command with the given flags parsed
+func testTestCommand(t *testing.T, flags ...string) *cobra.Command {
+	t.Helper()
+	cmd := newTestCmd()
+	if err := cmd.ParseFlags(flags); err != nil {
+		t.Fatalf("Failed to parse flags: %v", err)
+	}
+	return cmd
+}
+
+func TestExecuteTests_WritesReports(t *testing.T) {
+	tempDir := t.TempDir()
+	readme := "<!-- RR[Setup]\necho setup\n-->\n\n" +
+		"<!-- RR[Passes]{test needs=Setup}\necho hello\nexpect:\nhello\n-->\n\n" +
+		"<!-- RR[Fails]{test}\nfalse\n-->\n\n" +
+		"<!-- RR[Not a test]\ntouch not-a-test\n-->"
+	os.WriteFile(filepath.Join(tempDir, "README.md"), []byte(readme), 0644)
+	junit := filepath.Join(tempDir, "junit.xml")
+	tap := filepath.Join(tempDir, "results.tap")
+
+	err := executeTests(testTestCommand(t, "--path", tempDir, "--junit", junit, "--tap", tap), nil)
+	if exitCode(err) != ExitBlockFailed {
+		t.Fatalf("Expected the failing test to fail the run, got %v", err)
+	}
+
+	content, _ := os.ReadFile(tap)
+	if !strings.HasPrefix(string(content), "TAP version 13\n1..3\nok 1 - Setup\nok 2 - Passes\nnot ok 3 - Fails\n") {
+		t.Errorf("Unexpected TAP report:\n%s", content)
+	}
+	content, _ = os.ReadFile(junit)
+	if !strings.Contains(string(content), `tests="3" failures="1"`) {
+		t.Errorf("Unexpected JUnit report:\n%s", content)
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, "not-a-test")); err == nil {
+		t.Error("Expected blocks without the test attribute to be left out")
+	}
+	if _, err := os.Stat(filepath.Join(tempDir, ".rr")); err == nil {
+		t.Error("Expected a test run not to record approvals")
+	}
+}
@@ -0,0 +1,64 @@
+package rr
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ApprovalStore remembers which blocks have been approved by storing their hashes
+// in a .rr file in the project directory
+type ApprovalStore struct {
+	path string
+}
+
+// NewApprovalStore returns the approval store of the given project directory
+func NewApprovalStore(workDir string) *ApprovalStore {
+	return &ApprovalStore{path: filepath.Join(workDir, ".rr")}
+}
+
+// Approved reads the .rr file and returns a map of approved block hashes
+func (s *ApprovalStore) Approved() map[string]bool {
+	approvedHashes := make(map[string]bool)
+
+	if _, err := os.Stat(s.path); os.IsNotExist(err) {
+		return approvedHashes
+	}
+
+	content, err := os.ReadFile(s.path)
+	if err != nil {
+		//don't crash if we can't read the file.
+		return approvedHashes
+	}
+
+	lines := strings.Split(string(content), "\n")
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line != "" {
+			approvedHashes[line] = true
+		}
+	}
+
+	return approvedHashes
+}
+
+// IsApproved reports whether the block with the given hash has been approved
+func (s *ApprovalStore) IsApproved(hash string) bool {
+	return s.Approved()[hash]
+}
+
+// Approve appends a block hash to the .rr file
+func (s *ApprovalStore) Approve(hash string) error {
+	if s.IsApproved(hash) {
+		return nil
+	}
+
+	file, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	_, err = file.WriteString(hash + "\n")
+	return err
+}
@@ -0,0 +1,165 @@
+package rr
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestApprovalStore_Approved_NonExistentFile(t *testing.T) {
+	tempDir := t.TempDir()
+	hashes := NewApprovalStore(tempDir).Approved()
+
+	if len(hashes) != 0 {
+		t.Errorf("Expected empty map for non-existent file, got %d entries", len(hashes))
+	}
+}
+
+func TestApprovalStore_Approved_ExistingFile(t *testing.T) {
+	tempDir := t.TempDir()
+	rrFile := filepath.Join(tempDir, ".rr")
+
+	content := "hash1\nhash2\nhash3\n"
+	err := os.WriteFile(rrFile, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create .rr file: %v", err)
+	}
+
+	hashes := NewApprovalStore(tempDir).Approved()
+
+	if len(hashes) != 3 {
+		t.Fatalf("Expected 3 hashes, got %d", len(hashes))
+	}
+
+	if !hashes["hash1"] {
+		t.Error("Expected hash1 to be in approved hashes")
+	}
+	if !hashes["hash2"] {
+		t.Error("Expected hash2 to be in approved hashes")
+	}
+	if !hashes["hash3"] {
+		t.Error("Expected hash3 to be in approved hashes")
+	}
+}
+
+func TestApprovalStore_Approved_EmptyFile(t *testing.T) {
+	tempDir := t.TempDir()
+	rrFile := filepath.Join(tempDir, ".rr")
+
+	err := os.WriteFile(rrFile, []byte(""), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create .rr file: %v", err)
+	}
+
+	hashes := NewApprovalStore(tempDir).Approved()
+
+	if len(hashes) != 0 {
+		t.Errorf("Expected empty map for empty file, got %d entries", len(hashes))
+	}
+}
+
+func TestApprovalStore_Approve_NewHash(t *testing.T) {
+	tempDir := t.TempDir()
+	hash := "test-hash-123"
+
+	NewApprovalStore(tempDir).Approve(hash)
+
+	// Verify hash was saved
+	hashes := NewApprovalStore(tempDir).Approved()
+	if !hashes[hash] {
+		t.Error("Expected hash to be saved")
+	}
+}
+
+func TestApprovalStore_Approve_DuplicateHash(t *testing.T) {
+	tempDir := t.TempDir()
+	hash := "test-hash-123"
+
+	// Save hash twice
+	NewApprovalStore(tempDir).Approve(hash)
+	NewApprovalStore(tempDir).Approve(hash)
+
+	// Read file and count occurrences
+	rrFile := filepath.Join(tempDir, ".rr")
+	content, err := os.ReadFile(rrFile)
+	if err != nil {
+		t.Fatalf("Failed to read .rr file: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	if len(lines) != 1 {
+		t.Errorf("Expected hash to appear once, found %d times", len(lines))
+	}
+}
+
+func TestApprovalStore_Approve_MultipleHashes(t *testing.T) {
+	tempDir := t.TempDir()
+	hash1 := "hash1"
+	hash2 := "hash2"
+	hash3 := "hash3"
+
+	NewApprovalStore(tempDir).Approve(hash1)
+	NewApprovalStore(tempDir).Approve(hash2)
+	NewApprovalStore(tempDir).Approve(hash3)
+
+	hashes := NewApprovalStore(tempDir).Approved()
+	if len(hashes) != 3 {
+		t.Fatalf("Expected 3 hashes, got %d", len(hashes))
+	}
+
+	if !hashes[hash1] || !hashes[hash2] || !hashes[hash3] {
+		t.Error("Expected all hashes to be saved")
+	}
+}
+
+func TestApprovalStore_Approved_WithWhitespace(t *testing.T) {
+	tempDir := t.TempDir()
+	rrFile := filepath.Join(tempDir, ".rr")
+
+	content := "hash1\n  hash2  \n\nhash3\n"
+	err := os.WriteFile(rrFile, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create .rr file: %v", err)
+	}
+
+	hashes := NewApprovalStore(tempDir).Approved()
+
+	// Should have 3 hashes, whitespace should be trimmed
+	if len(hashes) != 3 {
+		t.Fatalf("Expected 3 hashes, got %d", len(hashes))
+	}
+
+	if !hashes["hash1"] || !hashes["hash2"] || !hashes["hash3"] {
+		t.Error("Expected all hashes to be loaded (whitespace trimmed)")
+	}
+}
+
+func TestApprovalStore_Integration(t *testing.T) {
+	tempDir := t.TempDir()
+	hash1 := "abc123"
+	hash2 := "def456"
+	hash3 := "ghi789"
+
+	// Save hashes
+	NewApprovalStore(tempDir).Approve(hash1)
+	NewApprovalStore(tempDir).Approve(hash2)
+	NewApprovalStore(tempDir).Approve(hash3)
+
+	// Load and verify
+	hashes := NewApprovalStore(tempDir).Approved()
+	if len(hashes) != 3 {
+		t.Fatalf("Expected 3 hashes after save, got %d", len(hashes))
+	}
+
+	// Verify each hash
+	if !hashes[hash1] {
+		t.Error("hash1 not found after save/load")
+	}
+	if !hashes[hash2] {
+		t.Error("hash2 not found after save/load")
+	}
+	if !hashes[hash3] {
+		t.Error("hash3 not found after save/load")
+	}
+}
@@ -0,0 +1,388 @@
+package rr
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultReadyTimeout = time.Minute            // how long a background block may take to become ready
+	readyPollInterval   = 250 * time.Millisecond // wait between two rounds of readiness checks
+	readyCheckTimeout   = 5 * time.Second        // longest a single readiness check may take
+	stopGracePeriod     = 5 * time.Second        // wait for background processes to exit before killing them
+)
+
+// backgroundBlock is a started background block. Its commands keep running, and the processes
+// they start are left alone, until the run ends.
+type backgroundBlock struct {
+	label   string
+	out     io.Writer // where messages about the block go
+	session *shellSession
+	kill    context.CancelFunc // kills the session's process group
+	done    chan struct{}      // closed once the block's commands have finished
+	err     error              // why the commands stopped, valid once done is closed
+
+	stopping atomic.Bool
+	stopOnce sync.Once
+}
+
+// startBackground starts the commands of a background block one after the other in a shell of
+// their own and returns once the block's readiness checks pass. The commands get no input, and
+// their output is labelled with the block unless it already is.
+func (r *Runner) startBackground(ctx context.Context, block Block, blockVars, runVars map[string]string, out blockIO) error {
+	if _, expects := blockExpectation(block); expects {
+		return fmt.Errorf("background blocks can't expect output or an exit code")
+	}
+	interpreter, isScript := blockInterpreter(block)
+	if err := checkInterpreter(interpreter); err != nil {
+		return err
+	}
+	shell := interpreter
+	if isScript {
+		shell = "sh"
+	}
+
+	variables := mergeVariables(r.opts.EnvVars, runVars, blockVars)
+	var commands, labels []string
+	var scripts []func() // remove the script files once the commands have finished
+	removeScripts := func() {
+		for _, remove := range scripts {
+			remove()
+		}
+	}
+	for _, cmd := range block.Commands {
+		if _, _, isCapture := ParseCapture(cmd); isCapture && !isScript {
+			return fmt.Errorf("background blocks can't capture output: %s", cmd)
+		}
+		cmd = substituteVariables(cmd, variables)
+		label := cmd
+		if isScript {
+			scriptCmd, remove, err := writeScript(interpreter, cmd)
+			if err != nil {
+				removeScripts()
+				return fmt.Errorf("error writing script: %v", err)
+			}
+			scripts = append(scripts, remove)
+			cmd, label = scriptCmd, interpreter+" script"
+		}
+		commands = append(commands, cmd)
+		labels = append(labels, label)
+	}
+
+	env := commandEnvironment(block, r.opts, variables)
+	dir, err := blockDir(block, r.opts, variables)
+	if err != nil {
+		removeScripts()
+		return err
+	}
+	checks, logMatch, err := readinessChecks(block, variables, dir, env)
+	if err != nil {
+		removeScripts()
+		return err
+	}
+
+	stdout, stderr := out.stdout, out.stderr
+	if !out.labelled {
+		// The block's output carries on while later blocks run, so tell it apart from theirs
+		var mu sync.Mutex
+		stdout = newPrefixWriter(stdout, &mu, "["+out.label+"] ")
+		stderr = newPrefixWriter(stderr, &mu, "["+out.label+"] ")
+	}
+	shellOut, shellErr := stdout, stderr
+	if logMatch != nil {
+		shellOut = io.MultiWriter(stdout, logMatch.writer())
+		shellErr = io.MultiWriter(stderr, logMatch.writer())
+	}
+	shellOut, shellErr = r.eventOutput(out.index, shellOut, shellErr)
+
+	session, err := startShellSession(shell, dir, nil, shellOut, shellErr, env)
+	if err != nil {
+		removeScripts()
+		return fmt.Errorf("error starting shell: %v", err)
+	}
+
+	// The commands outlive the batch that started them; stop ends them, or ctx while the block isn't ready yet
+	runCtx, kill := context.WithCancel(context.WithoutCancel(ctx))
+	bg := &backgroundBlock{label: out.label, out: out.stdout, session: session, kill: kill, done: make(chan struct{})}
+	r.backgroundMu.Lock()
+	r.background = append(r.background, bg)
+	r.backgroundMu.Unlock()
+
+	started := make(chan struct{}) // the first command has been announced
+	go func() {
+		defer close(bg.done)
+		defer removeScripts()
+		defer closeOnce(started)
+		for i, cmd := range commands {
+			fmt.Fprintf(stdout, "\nExecuting in the background: %s\n", labels[i])
+			closeOnce(started)
+			end := r.startCommand(out.index, labels[i])
+			err := session.run(runCtx, cmd)
+			end(err)
+			if err != nil {
+				bg.err = &CommandError{Command: labels[i], ExitCode: exitStatus(err), Err: err}
+				if !bg.stopping.Load() {
+					fmt.Fprintf(stdout, "\nBackground block stopped: %v\n", bg.err)
+				}
+				return
+			}
+		}
+	}()
+
+	<-started
+	if err := bg.waitReady(ctx, stdout, checks, durationAttribute(block, "ready-timeout", defaultReadyTimeout), r.interruption); err != nil {
+		bg.stop()
+		return err
+	}
+	return nil
+}
+
+// waitReady polls the readiness checks until all of them have passed. It fails when the block's
+// commands fail first or the checks don't pass within timeout. Commands that finish successfully
+// may have started a daemon, so the checks carry on after them. Waiting ends early when the run is interrupted.
+func (b *backgroundBlock) waitReady(ctx context.Context, out io.Writer, checks []readinessCheck, timeout time.Duration, interruption func() os.Signal) error {
+	if len(checks) == 0 {
+		return nil
+	}
+
+	var descriptions []string
+	for _, check := range checks {
+		descriptions = append(descriptions, check.description)
+	}
+	fmt.Fprintf(out, "Waiting until ready: %s\n", strings.Join(descriptions, ", "))
+
+	waitCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	ticker := time.NewTicker(readyPollInterval)
+	defer ticker.Stop()
+
+	done := b.done
+	for {
+		var pending []readinessCheck
+		for _, check := range checks {
+			if !check.ready(waitCtx) {
+				pending = append(pending, check)
+			}
+		}
+		checks = pending
+		if len(checks) == 0 {
+			fmt.Fprintln(out, "Ready")
+			return nil
+		}
+		if sig := interruption(); sig != nil {
+			return &InterruptedError{Signal: sig}
+		}
+
+		select {
+		case <-done:
+			if b.err != nil {
+				return b.err
+			}
+			done = nil // finished successfully, keep waiting for what it started
+		case <-waitCtx.Done():
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			notReady := &NotReadyError{Limit: timeout}
+			for _, check := range checks {
+				notReady.Pending = append(notReady.Pending, check.description)
+			}
+			return notReady
+		case <-ticker.C:
+		}
+	}
+}
+
+// stop asks the block's processes to exit and kills the ones that are still running after
+// stopGracePeriod. It is safe to call more than once.
+func (b *backgroundBlock) stop() {
+	b.stopOnce.Do(func() {
+		b.stopping.Store(true)
+		fmt.Fprintf(b.out, "\nStopping background block %s\n", b.label)
+
+		process := b.session.cmd.Process
+		terminateProcessGroup(process)
+		deadline := time.Now().Add(stopGracePeriod)
+		for time.Now().Before(deadline) && !isClosed(b.done) {
+			time.Sleep(50 * time.Millisecond)
+		}
+		if isClosed(b.done) {
+			// Reap the shell so only the processes it left behind count below
+			b.session.close()
+		}
+		for time.Now().Before(deadline) && processGroupExists(process) {
+			time.Sleep(50 * time.Millisecond)
+		}
+
+		killProcessGroup(process)
+		b.kill()
+		<-b.done
+		b.session.close()
+	})
+}
+
+// stopBackground stops the background blocks of the run, the most recently started one first
+func (r *Runner) stopBackground() {
+	r.backgroundMu.Lock()
+	blocks := r.background
+	r.background = nil
+	r.backgroundMu.Unlock()
+
+	for i := len(blocks) - 1; i >= 0; i-- {
+		blocks[i].stop()
+	}
+}
+
+// isClosed reports whether a channel has been closed, without waiting
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+// closeOnce closes a channel unless it is closed already
+func closeOnce(ch chan struct{}) {
+	if !isClosed(ch) {
+		close(ch)
+	}
+}
+
+// readinessSummary describes what a background block waits for, e.g. "port localhost:8080, command curl -f ...",
+// with the variables that are known before the run substituted
+func (r *Runner) readinessSummary(block Block, globals map[string]string) string {
+	variables := mergeVariables(r.opts.EnvVars, withoutPrompts(globals), withoutPrompts(block.Variables))
+	checks, _, _ := readinessChecks(block, variables, "", nil)
+	var descriptions []string
+	for _, check := range checks {
+		descriptions = append(descriptions, check.description)
+	}
+	if len(descriptions) == 0 {
+		return "nothing"
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+// readinessCheck is a condition a background block waits for before the run moves on
+type readinessCheck struct {
+	description string
+	ready       func(ctx context.Context) bool
+}
+
+// readinessChecks builds the checks from the ready-* attributes of a background block, with
+// variables substituted into their values. A ready-log check also returns the matcher that
+// the block's output has to be fed to.
+func readinessChecks(block Block, variables map[string]string, dir string, env []string) ([]readinessCheck, *logMatch, error) {
+	attribute := func(name string) (string, bool) {
+		value, exists := block.Attributes[name]
+		return substituteVariables(value, variables), exists
+	}
+
+	var checks []readinessCheck
+	if port, exists := attribute("ready-port"); exists {
+		address := port
+		if !strings.Contains(address, ":") {
+			address = "localhost:" + address
+		}
+		checks = append(checks, readinessCheck{
+			description: "port " + address,
+			ready: func(ctx context.Context) bool {
+				dialer := net.Dialer{Timeout: time.Second}
+				conn, err := dialer.DialContext(ctx, "tcp", address)
+				if err != nil {
+					return false
+				}
+				conn.Close()
+				return true
+			},
+		})
+	}
+	if path, exists := attribute("ready-file"); exists {
+		checks = append(checks, readinessCheck{
+			description: "file " + path,
+			ready: func(context.Context) bool {
+				_, err := os.Stat(resolvePath(dir, path))
+				return err == nil
+			},
+		})
+	}
+	if command, exists := attribute("ready-cmd"); exists {
+		checks = append(checks, readinessCheck{
+			description: "command " + command,
+			ready: func(ctx context.Context) bool {
+				ctx, cancel := context.WithTimeout(ctx, readyCheckTimeout)
+				defer cancel()
+				check := exec.CommandContext(ctx, "sh", "-c", command)
+				check.Dir = dir
+				check.Env = env
+				return check.Run() == nil
+			},
+		})
+	}
+
+	var match *logMatch
+	if pattern, exists := attribute("ready-log"); exists {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid ready-log pattern %q: %v", pattern, err)
+		}
+		match = &logMatch{re: re, found: make(chan struct{})}
+		checks = append(checks, readinessCheck{
+			description: "output matching " + pattern,
+			ready: func(context.Context) bool {
+				return isClosed(match.found)
+			},
+		})
+	}
+	return checks, match, nil
+}
+
+// logMatch watches the output of a background block for a line matching a pattern
+type logMatch struct {
+	re    *regexp.Regexp
+	found chan struct{} // closed once a line matched
+	once  sync.Once
+}
+
+// writer returns a writer for one output stream of the block. Each stream needs its own,
+// so that lines are put together from the right pieces.
+func (m *logMatch) writer() io.Writer {
+	return &logMatchWriter{match: m}
+}
+
+// logMatchWriter splits one output stream into lines and matches them against the pattern
+type logMatchWriter struct {
+	match *logMatch
+	line  []byte
+}
+
+func (w *logMatchWriter) Write(data []byte) (int, error) {
+	w.line = append(w.line, data...)
+	for {
+		end := bytes.IndexByte(w.line, '\n')
+		if end < 0 {
+			break
+		}
+		if w.match.re.Match(w.line[:end]) {
+			w.match.once.Do(func() { close(w.match.found) })
+		}
+		w.line = w.line[end+1:]
+	}
+	// A prompt-like line that never ends, e.g. "ready> ", still counts
+	if len(w.line) > 0 && w.match.re.Match(w.line) {
+		w.match.once.Do(func() { close(w.match.found) })
+	}
+	return len(data), nil
+}
@@ -0,0 +1,140 @@
+package rr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun_BackgroundBlockIsStoppedWhenRunEnds(t *testing.T) {
+	tempDir := t.TempDir()
+	ready := filepath.Join(tempDir, "ready")
+	late := filepath.Join(tempDir, "late")
+	doc := Parse("<!-- RR[Server]{background ready-file=" + ready + "}\nsleep 0.2; touch " + ready + "; sleep 1; touch " + late + "\n-->\n" +
+		"<!-- RR[Client]\ntest -e " + ready + "\necho client ran\n-->")
+
+	var stdout bytes.Buffer
+	runner := NewRunner(Options{Stdout: &stdout, Trust: true})
+	if err := runner.Run(context.Background(), doc); err != nil {
+		t.Fatalf("Expected the client to run once the server was ready, got %v", err)
+	}
+
+	output := stdout.String()
+	for _, expected := range []string{"[Server] Waiting until ready: file " + ready, "[Server] Ready", "client ran", "Stopping background block Server"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected output to contain %q, got %q", expected, output)
+		}
+	}
+	if strings.Index(output, "Stopping background block") > strings.Index(output, "--- Summary ---") {
+		t.Error("Expected background blocks to be stopped before the summary")
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+	if _, err := os.Stat(late); err == nil {
+		t.Error("Expected the background block's processes to be stopped at the end of the run")
+	}
+}
+
+func TestRun_BackgroundReadyLog(t *testing.T) {
+	doc := Parse("<!-- RR[Server]{background ready-log=\"listening on [0-9]+\"}\necho starting; sleep 0.2; echo listening on 8080; sleep 30\n-->\n" +
+		"<!-- RR[After]\ntrue\n-->")
+
+	var stdout bytes.Buffer
+	runner := NewRunner(Options{Stdout: &stdout, Trust: true})
+	start := time.Now()
+	if err := runner.Run(context.Background(), doc); err != nil {
+		t.Fatalf("Expected run to succeed, got %v", err)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Error("Expected the background block to be stopped without waiting for it")
+	}
+	if !strings.Contains(stdout.String(), "[Server] listening on 8080") {
+		t.Errorf("Expected labelled background output, got %q", stdout.String())
+	}
+}
+
+func TestRun_BackgroundNotReady(t *testing.T) {
+	missing := filepath.Join(t.TempDir(), "missing")
+	doc := Parse("<!-- RR[Server]{background ready-file=" + missing + " ready-timeout=300ms}\nsleep 30\n-->\n" +
+		"<!-- RR[After]\ntrue\n-->")
+
+	runner := NewRunner(Options{Stdout: &bytes.Buffer{}, Trust: true})
+	err := runner.Run(context.Background(), doc)
+
+	var notReady *NotReadyError
+	if !errors.As(err, &notReady) || notReady.Limit != 300*time.Millisecond {
+		t.Fatalf("Expected NotReadyError, got %v", err)
+	}
+	if len(notReady.Pending) != 1 || notReady.Pending[0] != "file "+missing {
+		t.Errorf("Expected the pending check to be named, got %v", notReady.Pending)
+	}
+	if statuses := resultStatuses(runner); len(statuses) != 2 || statuses[0] != StatusFailed || statuses[1] != StatusSkipped {
+		t.Errorf("Expected [failed skipped], got %v", statuses)
+	}
+}
+
+func TestRun_BackgroundExitsBeforeReady(t *testing.T) {
+	doc := Parse("<!-- RR[Server]{background ready-file=/nonexistent/ready}\nexit_code() { return 3; }; exit_code\n-->")
+
+	runner := NewRunner(Options{Stdout: &bytes.Buffer{}, Trust: true})
+	start := time.Now()
+	err := runner.Run(context.Background(), doc)
+
+	var blockErr *BlockFailedError
+	if !errors.As(err, &blockErr) || blockErr.ExitCode != 3 {
+		t.Fatalf("Expected the block to fail with the command's exit code, got %v", err)
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Error("Expected the failure to be reported without waiting for the ready timeout")
+	}
+}
+
+func TestReadinessChecks_Port(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	address := listener.Addr().String()
+
+	block := Block{Attributes: map[string]string{"ready-port": "#address"}}
+	checks, _, err := readinessChecks(block, map[string]string{"address": address}, "", nil)
+	if err != nil || len(checks) != 1 {
+		t.Fatalf("Expected one check, got %v (%v)", checks, err)
+	}
+	if checks[0].description != "port "+address {
+		t.Errorf("Expected variables to be substituted into the check, got '%s'", checks[0].description)
+	}
+	if !checks[0].ready(context.Background()) {
+		t.Error("Expected the port check to pass while the port is open")
+	}
+
+	listener.Close()
+	if checks[0].ready(context.Background()) {
+		t.Error("Expected the port check to fail once the port is closed")
+	}
+}
+
+func TestReadinessChecks_Command(t *testing.T) {
+	block := Block{Attributes: map[string]string{"ready-cmd": "test -n \"$RR_READY\"", "ready-port": "5432"}}
+
+	checks, _, err := readinessChecks(block, nil, "", []string{"RR_READY=yes"})
+	if err != nil || len(checks) != 2 {
+		t.Fatalf("Expected two checks, got %v (%v)", checks, err)
+	}
+	if checks[0].description != "port localhost:5432" {
+		t.Errorf("Expected a bare port to mean localhost, got '%s'", checks[0].description)
+	}
+	if !checks[1].ready(context.Background()) {
+		t.Error("Expected the command check to pass when the command succeeds")
+	}
+
+	if _, _, err := readinessChecks(Block{Attributes: map[string]string{"ready-log": "("}}, nil, "", nil); err == nil {
+		t.Error("Expected an error for an invalid ready-log pattern")
+	}
+}
@@ -0,0 +1,198 @@
+package rr
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Block represents a parsed ReadMe Runner block
+type Block struct {
+	Name       string
+	Line       int    // 1-based line of the block header in the readme
+	Language   string // language of a fenced block, e.g. bash or python
+	Attributes map[string]string
+	Tags       []string
+	Needs      []string // names of the blocks that have to run before this one
+	Variables  map[string]string
+	Commands   []string
+
+	// ExpectedOutput is what the commands are expected to print, if HasExpectedOutput is set.
+	// It comes from an expect: section in the block or an output fence right after it.
+	ExpectedOutput    string
+	HasExpectedOutput bool
+}
+
+// Document is a parsed readme: its RR blocks and the global variables shared by all of them
+type Document struct {
+	Blocks  []Block
+	Globals map[string]string
+}
+
+// newBlock creates an empty block for a header found on the given line
+func newBlock(name string, line int, attributes map[string]string) *Block {
+	return &Block{
+		Name:       name,
+		Line:       line,
+		Attributes: attributes,
+		Tags:       splitList(attributes["tags"]),
+		Needs:      splitList(attributes["needs"]),
+		Variables:  make(map[string]string),
+		Commands:   []string{},
+	}
+}
+
+// boolAttribute reads a true/false block attribute, falling back to the given value when it isn't set or invalid
+func boolAttribute(block Block, name string, fallback bool) bool {
+	value, exists := block.Attributes[name]
+	if !exists {
+		return fallback
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return fallback
+	}
+	return parsed
+}
+
+// intAttribute reads a non-negative number block attribute, falling back to the given value when it isn't set or invalid.
+// Invalid values of the attributes in numberAttributes are reported by validateAttributes before a run.
+func intAttribute(block Block, name string, fallback int) int {
+	value, exists := block.Attributes[name]
+	if !exists {
+		return fallback
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil || parsed < 0 {
+		return fallback
+	}
+	return parsed
+}
+
+// durationAttribute reads a duration block attribute such as 30s or 5m, falling back to the given
+// value when it isn't set or invalid. Invalid values of the attributes in durationAttributes are
+// reported by validateAttributes before a run.
+func durationAttribute(block Block, name string, fallback time.Duration) time.Duration {
+	value, exists := block.Attributes[name]
+	if !exists {
+		return fallback
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil || parsed < 0 {
+		return fallback
+	}
+	return parsed
+}
+
+// numberAttributes are the block attributes that hold a non-negative number
+var numberAttributes = []string{"retries", "expect-exit"}
+
+// durationAttributes are the block attributes that hold a duration
+var durationAttributes = []string{"timeout", "command-timeout", "ready-timeout", "backoff"}
+
+// validateAttributes checks the values of the attributes that change how blocks run, so a typo
+// such as timeout=1 stops the run before any block runs rather than being ignored
+func validateAttributes(blocks []Block) error {
+	for i, block := range blocks {
+		for _, name := range numberAttributes {
+			value, exists := block.Attributes[name]
+			if !exists {
+				continue
+			}
+			if parsed, err := strconv.Atoi(value); err != nil || parsed < 0 {
+				return &AttributeError{Index: i + 1, Name: block.Name, Attribute: name, Value: value,
+					Reason: "is not a number of 0 or more"}
+			}
+		}
+		for _, name := range durationAttributes {
+			value, exists := block.Attributes[name]
+			if !exists {
+				continue
+			}
+			if parsed, err := time.ParseDuration(value); err != nil || parsed < 0 {
+				return &AttributeError{Index: i + 1, Name: block.Name, Attribute: name, Value: value,
+					Reason: "is not a duration such as 30s or 5m"}
+			}
+		}
+		if policy, exists := block.Attributes["on-error"]; exists {
+			switch policy {
+			case OnErrorAbort, OnErrorContinue, OnErrorPrompt:
+			default:
+				return &AttributeError{Index: i + 1, Name: block.Name, Attribute: "on-error", Value: policy,
+					Reason: "is not one of abort, continue or prompt"}
+			}
+		}
+	}
+	return nil
+}
+
+// HashBlock creates a SHA256 hash of the block content
+// The hash includes block name, commands, and variables to uniquely identify the block
+func HashBlock(block Block) string {
+	return HashBlockWithGlobals(block, nil)
+}
+
+// HashBlockWithGlobals hashes a block like HashBlock, adding the values of the global variables
+// its commands and attributes use, so changing one of them in RR-VARS needs a new approval.
+// Prompted globals are answered at run time and left out. Blocks that use no globals hash as
+// HashBlock does.
+func HashBlockWithGlobals(block Block, globals map[string]string) string {
+	var content strings.Builder
+	content.WriteString("name:" + block.Name + "\n")
+
+	var varKeys []string
+	for k := range block.Variables {
+		varKeys = append(varKeys, k)
+	}
+
+	for i := 0; i < len(varKeys)-1; i++ {
+		for j := i + 1; j < len(varKeys); j++ {
+			if varKeys[i] > varKeys[j] {
+				varKeys[i], varKeys[j] = varKeys[j], varKeys[i]
+			}
+		}
+	}
+	for _, k := range varKeys {
+		content.WriteString("var:" + k + "=" + block.Variables[k] + "\n")
+	}
+
+	// Attributes change how a block runs, so they are part of what gets approved.
+	// Blocks without attributes hash exactly as they did before attributes existed.
+	var attrKeys []string
+	for k := range block.Attributes {
+		attrKeys = append(attrKeys, k)
+	}
+	sort.Strings(attrKeys)
+	for _, k := range attrKeys {
+		content.WriteString("attr:" + k + "=" + block.Attributes[k] + "\n")
+	}
+
+	// The interpreter a fence language picks changes what runs, so it is part of the hash. Blocks without
+	// a fence language or with an sh fence hash as they did before languages were inferred, and the
+	// shell and lang attributes are already hashed above.
+	_, hasShell := block.Attributes["shell"]
+	_, hasLang := block.Attributes["lang"]
+	if interpreter, _ := blockInterpreter(block); !hasShell && !hasLang && interpreter != "sh" {
+		content.WriteString("interpreter:" + interpreter + "\n")
+	}
+
+	used := usedGlobals(block, globals)
+	var globalKeys []string
+	for k := range used {
+		globalKeys = append(globalKeys, k)
+	}
+	sort.Strings(globalKeys)
+	for _, k := range globalKeys {
+		content.WriteString("global:" + k + "=" + used[k] + "\n")
+	}
+
+	for _, cmd := range block.Commands {
+		content.WriteString("cmd:" + cmd + "\n")
+	}
+
+	hash := sha256.Sum256([]byte(content.String()))
+	return hex.EncodeToString(hash[:])
+}
@@ -0,0 +1,205 @@
+package rr
+
+import (
+	"testing"
+)
+
+func TestHashBlock_Consistency(t *testing.T) {
+	block1 := Block{
+		Name: "Test",
+		Variables: map[string]string{
+			"var1": "value1",
+			"var2": "value2",
+		},
+		Commands: []string{"echo test"},
+	}
+
+	block2 := Block{
+		Name: "Test",
+		Variables: map[string]string{
+			"var1": "value1",
+			"var2": "value2",
+		},
+		Commands: []string{"echo test"},
+	}
+
+	hash1 := HashBlock(block1)
+	hash2 := HashBlock(block2)
+
+	if hash1 != hash2 {
+		t.Errorf("Expected same hash for identical blocks, got %s and %s", hash1, hash2)
+	}
+}
+
+func TestHashBlock_DifferentContent(t *testing.T) {
+	block1 := Block{
+		Name:      "Test",
+		Variables: map[string]string{"var": "value1"},
+		Commands:  []string{"echo test"},
+	}
+
+	block2 := Block{
+		Name:      "Test",
+		Variables: map[string]string{"var": "value2"},
+		Commands:  []string{"echo test"},
+	}
+
+	hash1 := HashBlock(block1)
+	hash2 := HashBlock(block2)
+
+	if hash1 == hash2 {
+		t.Error("Expected different hashes for different content")
+	}
+}
+
+func TestHashBlock_IncludesName(t *testing.T) {
+	block1 := Block{
+		Name:      "Block1",
+		Variables: map[string]string{},
+		Commands:  []string{"echo test"},
+	}
+
+	block2 := Block{
+		Name:      "Block2",
+		Variables: map[string]string{},
+		Commands:  []string{"echo test"},
+	}
+
+	hash1 := HashBlock(block1)
+	hash2 := HashBlock(block2)
+
+	if hash1 == hash2 {
+		t.Error("Expected different hashes for blocks with different names")
+	}
+}
+
+func TestHashBlock_EmptyBlock(t *testing.T) {
+	block := Block{
+		Name:      "",
+		Variables: map[string]string{},
+		Commands:  []string{},
+	}
+
+	hash := HashBlock(block)
+	if hash == "" {
+		t.Error("Expected non-empty hash even for empty block")
+	}
+}
+
+func TestHashBlock_OrderIndependent(t *testing.T) {
+	// Test that variable order doesn't affect hash (due to sorting)
+	block1 := Block{
+		Name: "Test",
+		Variables: map[string]string{
+			"a": "1",
+			"b": "2",
+		},
+		Commands: []string{"echo test"},
+	}
+
+	block2 := Block{
+		Name: "Test",
+		Variables: map[string]string{
+			"b": "2",
+			"a": "1",
+		},
+		Commands: []string{"echo test"},
+	}
+
+	hash1 := HashBlock(block1)
+	hash2 := HashBlock(block2)
+
+	if hash1 != hash2 {
+		t.Error("Expected same hash for blocks with variables in different order")
+	}
+}
+
+func TestHashBlock_WithPrompts(t *testing.T) {
+	block1 := Block{
+		Name: "Test",
+		Variables: map[string]string{
+			"var": "#PROMPT:Enter value:",
+		},
+		Commands: []string{"echo test"},
+	}
+
+	block2 := Block{
+		Name: "Test",
+		Variables: map[string]string{
+			"var": "#PROMPT:Enter value:",
+		},
+		Commands: []string{"echo test"},
+	}
+
+	hash1 := HashBlock(block1)
+	hash2 := HashBlock(block2)
+
+	if hash1 != hash2 {
+		t.Error("Expected same hash for blocks with same prompt variables")
+	}
+}
+
+func TestHashBlock_CommandOrderMatters(t *testing.T) {
+	block1 := Block{
+		Name:      "Test",
+		Variables: map[string]string{},
+		Commands:  []string{"echo first", "echo second"},
+	}
+
+	block2 := Block{
+		Name:      "Test",
+		Variables: map[string]string{},
+		Commands:  []string{"echo second", "echo first"},
+	}
+
+	hash1 := HashBlock(block1)
+	hash2 := HashBlock(block2)
+
+	if hash1 == hash2 {
+		t.Error("Expected different hashes for blocks with different command order")
+	}
+}
+
+func TestHashBlock_IncludesAttributes(t *testing.T) {
+	block1 := Block{
+		Name:     "Test",
+		Commands: []string{"echo test"},
+	}
+	block2 := Block{
+		Name:       "Test",
+		Attributes: map[string]string{"cwd": "web"},
+		Commands:   []string{"echo test"},
+	}
+
+	if HashBlock(block1) == HashBlock(block2) {
+		t.Error("Expected different hashes for blocks with different attributes")
+	}
+}
+
+func TestHashBlockWithGlobals(t *testing.T) {
+	block := Block{
+		Name:      "Test",
+		Variables: map[string]string{"local": "value"},
+		Commands:  []string{"echo #target #local #question"},
+	}
+	globals := map[string]string{"target": "hello", "unused": "x", "local": "y", "question": "#PROMPT:Question?"}
+
+	if HashBlockWithGlobals(block, nil) != HashBlock(block) {
+		t.Error("Expected a block without globals to hash as HashBlock does")
+	}
+	hash := HashBlockWithGlobals(block, globals)
+	if hash == HashBlock(block) {
+		t.Error("Expected the value of a used global to be part of the hash")
+	}
+
+	changed := map[string]string{"target": "rm -rf SOMETHING", "unused": "x", "local": "y", "question": "#PROMPT:Question?"}
+	if HashBlockWithGlobals(block, changed) == hash {
+		t.Error("Expected a changed global value to change the hash")
+	}
+
+	// Unused globals, globals overridden by the block and prompted globals don't matter
+	other := map[string]string{"target": "hello", "unused": "z", "local": "z", "question": "#PROMPT:Other?"}
+	if HashBlockWithGlobals(block, other) != hash {
+		t.Error("Expected only the values of used, non-prompt globals to be part of the hash")
+	}
+}
@@ -0,0 +1,104 @@
+package rr
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// CheckpointStore remembers the progress of a run in a .rr-state file in the project directory,
+// so a run that failed or was interrupted can be resumed, see Options.Resume
+type CheckpointStore struct {
+	path string
+}
+
+// Checkpoint is the progress of a run: the blocks that completed and the values they captured
+type Checkpoint struct {
+	Blocks   []CompletedBlock  `json:"blocks"`
+	Captures map[string]string `json:"captures,omitempty"`
+}
+
+// CompletedBlock is a block that completed successfully. The hash tells whether the block has
+// changed since.
+type CompletedBlock struct {
+	Index int    `json:"index"`
+	Name  string `json:"name,omitempty"`
+	Hash  string `json:"hash"`
+}
+
+// NewCheckpointStore returns the checkpoint store of the given project directory
+func NewCheckpointStore(workDir string) *CheckpointStore {
+	return &CheckpointStore{path: filepath.Join(workDir, ".rr-state")}
+}
+
+// Load reads the checkpoint of the last run. Without a .rr-state file the checkpoint is empty.
+func (s *CheckpointStore) Load() (Checkpoint, error) {
+	var checkpoint Checkpoint
+	content, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return checkpoint, nil
+	}
+	if err != nil {
+		return checkpoint, err
+	}
+	err = json.Unmarshal(content, &checkpoint)
+	return checkpoint, err
+}
+
+// Save writes the checkpoint. Captured values may be secrets, so only the owner can read the file.
+func (s *CheckpointStore) Save(checkpoint Checkpoint) error {
+	content, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write a temporary file first, so an interrupted write never leaves half a checkpoint behind
+	temp, err := os.CreateTemp(filepath.Dir(s.path), ".rr-state-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(temp.Name())
+	if _, err := temp.Write(append(content, '\n')); err != nil {
+		temp.Close()
+		return err
+	}
+	if err := temp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(temp.Name(), s.path)
+}
+
+// Clear removes the checkpoint, so the next resumed run starts from the first block
+func (s *CheckpointStore) Clear() error {
+	if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// IsCompleted reports whether the block with the given hash completed in the checkpointed run
+func (c Checkpoint) IsCompleted(hash string) bool {
+	for _, block := range c.Blocks {
+		if block.Hash == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// complete records a block that completed successfully, along with the values it captured.
+// globals are the global variables of the document, whose values are part of the block's hash.
+// A block that is already recorded, e.g. one skipped by a resumed run, is recorded only once.
+func (c *Checkpoint) complete(index int, block Block, globals, runVars map[string]string) {
+	if hash := HashBlockWithGlobals(block, globals); !c.IsCompleted(hash) {
+		c.Blocks = append(c.Blocks, CompletedBlock{Index: index, Name: block.Name, Hash: hash})
+	}
+	for _, cmd := range block.Commands {
+		if varName, _, isCapture := ParseCapture(cmd); isCapture && !runsAsScript(block) {
+			if c.Captures == nil {
+				c.Captures = make(map[string]string)
+			}
+			c.Captures[varName] = runVars[varName]
+		}
+	}
+}
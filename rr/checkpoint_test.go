@@ -0,0 +1,67 @@
+package rr
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckpointStore_LoadWithoutFile(t *testing.T) {
+	checkpoint, err := NewCheckpointStore(t.TempDir()).Load()
+	if err != nil {
+		t.Fatalf("Expected no error without a .rr-state file, got %v", err)
+	}
+	if len(checkpoint.Blocks) != 0 || checkpoint.IsCompleted("hash") {
+		t.Errorf("Expected an empty checkpoint, got %+v", checkpoint)
+	}
+}
+
+func TestCheckpointStore_SaveLoadClear(t *testing.T) {
+	tempDir := t.TempDir()
+	store := NewCheckpointStore(tempDir)
+
+	block := Block{Name: "Token", Commands: []string{"TOKEN = #capture(echo secret)"}}
+	var checkpoint Checkpoint
+	checkpoint.complete(2, block, nil, map[string]string{"TOKEN": "secret"})
+	if err := store.Save(checkpoint); err != nil {
+		t.Fatalf("Failed to save checkpoint: %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(tempDir, ".rr-state"))
+	if err != nil {
+		t.Fatalf("Expected .rr-state next to .rr, got %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("Expected the state file to be private, got %v", info.Mode().Perm())
+	}
+
+	loaded, err := store.Load()
+	if err != nil {
+		t.Fatalf("Failed to load checkpoint: %v", err)
+	}
+	if len(loaded.Blocks) != 1 || loaded.Blocks[0].Index != 2 || loaded.Blocks[0].Name != "Token" || !loaded.IsCompleted(HashBlock(block)) {
+		t.Errorf("Expected the completed block to be loaded, got %+v", loaded.Blocks)
+	}
+	if loaded.Captures["TOKEN"] != "secret" {
+		t.Errorf("Expected the captured value to be loaded, got %v", loaded.Captures)
+	}
+
+	if err := store.Clear(); err != nil {
+		t.Fatalf("Failed to clear checkpoint: %v", err)
+	}
+	if loaded, _ := store.Load(); len(loaded.Blocks) != 0 {
+		t.Error("Expected the checkpoint to be gone after Clear")
+	}
+	if err := store.Clear(); err != nil {
+		t.Errorf("Expected clearing twice to succeed, got %v", err)
+	}
+}
+
+func TestCheckpointStore_InvalidFile(t *testing.T) {
+	tempDir := t.TempDir()
+	os.WriteFile(filepath.Join(tempDir, ".rr-state"), []byte("not json"), 0600)
+
+	if _, err := NewCheckpointStore(tempDir).Load(); err == nil {
+		t.Error("Expected an error for an unreadable state file")
+	}
+}
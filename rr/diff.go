@@ -0,0 +1,134 @@
+package rr
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diffContext is how many unchanged lines are shown around each change of a unified diff
+const diffContext = 3
+
+// diffLine is a line of a diff: ' ' for a line both sides have, '-' for a line only in the
+// expected text and '+' for a line only in the actual text
+type diffLine struct {
+	kind byte
+	text string
+}
+
+// unifiedDiff compares two texts line by line and returns the differences in unified diff format,
+// or "" if they are equal
+func unifiedDiff(expected, actual string) string {
+	lines := diffLines(splitLines(expected), splitLines(actual))
+
+	// Find the changed lines, then group them into hunks with some context around them
+	var out strings.Builder
+	for start := 0; start < len(lines); {
+		first := start
+		for first < len(lines) && lines[first].kind == ' ' {
+			first++
+		}
+		if first == len(lines) {
+			break
+		}
+
+		// A hunk ends once there are more unchanged lines than the context of two hunks
+		end := first
+		for i := first; i < len(lines); i++ {
+			if lines[i].kind != ' ' {
+				end = i + 1
+			} else if i-end > 2*diffContext {
+				break
+			}
+		}
+
+		from := max(first-diffContext, start)
+		to := min(end+diffContext, len(lines))
+		writeHunk(&out, lines, from, to)
+		start = to
+	}
+
+	if out.Len() == 0 {
+		return ""
+	}
+	return "--- expected\n+++ actual\n" + out.String()
+}
+
+// writeHunk writes the lines from..to of a diff as one hunk with its @@ header
+func writeHunk(out *strings.Builder, lines []diffLine, from, to int) {
+	// Line numbers of the hunk start are counted on each side
+	expectedLine, actualLine := 1, 1
+	for _, line := range lines[:from] {
+		if line.kind != '+' {
+			expectedLine++
+		}
+		if line.kind != '-' {
+			actualLine++
+		}
+	}
+	expectedCount, actualCount := 0, 0
+	for _, line := range lines[from:to] {
+		if line.kind != '+' {
+			expectedCount++
+		}
+		if line.kind != '-' {
+			actualCount++
+		}
+	}
+	if expectedCount == 0 {
+		expectedLine--
+	}
+	if actualCount == 0 {
+		actualLine--
+	}
+
+	fmt.Fprintf(out, "@@ -%d,%d +%d,%d @@\n", expectedLine, expectedCount, actualLine, actualCount)
+	for _, line := range lines[from:to] {
+		out.WriteByte(line.kind)
+		out.WriteString(line.text)
+		out.WriteByte('\n')
+	}
+}
+
+// diffLines lines up two lists of lines along their longest common subsequence
+func diffLines(a, b []string) []diffLine {
+	// common[i][j] is the length of the longest common subsequence of a[i:] and b[j:]
+	common := make([][]int, len(a)+1)
+	for i := range common {
+		common[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = max(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+
+	var lines []diffLine
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			lines = append(lines, diffLine{' ', a[i]})
+			i++
+			j++
+		case j == len(b) || (i < len(a) && common[i+1][j] >= common[i][j+1]):
+			lines = append(lines, diffLine{'-', a[i]})
+			i++
+		default:
+			lines = append(lines, diffLine{'+', b[j]})
+			j++
+		}
+	}
+	return lines
+}
+
+// splitLines splits a text into its lines. An empty text has no lines.
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
@@ -0,0 +1,28 @@
+package rr
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	expected := "a\nb\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl"
+	actual := "a\nB\nc\nd\ne\nf\ng\nh\ni\nj\nk\nl\nm"
+
+	want := "--- expected\n+++ actual\n" +
+		"@@ -1,5 +1,5 @@\n a\n-b\n+B\n c\n d\n e\n" +
+		"@@ -10,3 +10,4 @@\n j\n k\n l\n+m\n"
+	if got := unifiedDiff(expected, actual); got != want {
+		t.Errorf("Unexpected diff:\n%s\nexpected:\n%s", got, want)
+	}
+}
+
+func TestUnifiedDiff_Equal(t *testing.T) {
+	if diff := unifiedDiff("same\ntext", "same\ntext"); diff != "" {
+		t.Errorf("Expected no diff for equal texts, got %q", diff)
+	}
+}
+
+func TestUnifiedDiff_EmptySide(t *testing.T) {
+	want := "--- expected\n+++ actual\n@@ -0,0 +1,1 @@\n+hello\n"
+	if got := unifiedDiff("", "hello"); got != want {
+		t.Errorf("Expected %q, got %q", want, got)
+	}
+}
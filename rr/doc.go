@@ -0,0 +1,8 @@
+// Package rr parses and runs the RR blocks embedded in a project's readme file.
+//
+// A readme is parsed into a Document with Parse (or LoadProject), and its blocks are run
+// with a Runner. Approvals of blocks are remembered in an ApprovalStore, and variables
+// from .env files are loaded with LoadEnv.
+//
+// The readmerunner command is a thin wrapper around this package.
+package rr
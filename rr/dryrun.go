@@ -0,0 +1,169 @@
+package rr
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DryRun prints the commands of every selected block exactly as they would be sent to the shell,
+// in the order they would run. Nothing is executed, no prompts are shown and no approvals are written.
+func (r *Runner) DryRun(doc Document) error {
+	order, err := plan(doc.Blocks, r.opts.Filter)
+	if err != nil {
+		return err
+	}
+	commandCount, unresolvedCount := 0, 0
+
+	var checkpoint Checkpoint
+	if r.opts.Resume && r.opts.Checkpoints != nil {
+		if checkpoint, err = r.opts.Checkpoints.Load(); err != nil {
+			return fmt.Errorf("error reading the state of the last run: %w", err)
+		}
+	}
+
+	// Captured values are only known at run time, so remember which names will be captured
+	captured := make(map[string]bool)
+
+	for _, i := range order {
+		block := doc.Blocks[i]
+
+		fmt.Fprintf(r.stdout, "\n--- Block %d of %d (line %d) ---\n", i+1, len(doc.Blocks), block.Line)
+		if block.Name != "" {
+			fmt.Fprintf(r.stdout, "Block Name: %s\n", block.Name)
+		}
+		if interpreter := describeInterpreter(block); interpreter != "" {
+			fmt.Fprintf(r.stdout, "Interpreter: %s\n", interpreter)
+		}
+		if cwd, exists := block.Attributes["cwd"]; exists {
+			fmt.Fprintf(r.stdout, "Directory: %s\n", cwd)
+		}
+		if len(block.Needs) > 0 {
+			fmt.Fprintf(r.stdout, "Needs: %s\n", strings.Join(block.Needs, ", "))
+		}
+		if r.opts.Resume && !runsAlways(block) && checkpoint.IsCompleted(HashBlockWithGlobals(block, doc.Globals)) {
+			fmt.Fprintln(r.stdout, "Resume: completed in the last run, would be skipped")
+		}
+		if path, exists := block.Attributes["creates"]; exists {
+			fmt.Fprintf(r.stdout, "Guard: skipped if %s exists\n", path)
+		}
+		if command, exists := block.Attributes["unless"]; exists {
+			fmt.Fprintf(r.stdout, "Guard: skipped if %s succeeds\n", command)
+		}
+		if expect, expects := blockExpectation(block); expects {
+			expected := fmt.Sprintf("exit code %d", expect.exitCode)
+			if expect.hasOutput {
+				expected += fmt.Sprintf(", output (%s):\n%s", expect.match, expect.output)
+			}
+			fmt.Fprintf(r.stdout, "Expects: %s\n", expected)
+		}
+		if runsAlways(block) {
+			fmt.Fprintln(r.stdout, "Cleanup: yes, runs at the end even after failures")
+		}
+		if boolAttribute(block, "background", false) {
+			fmt.Fprintf(r.stdout, "Background: yes, waits for %s\n", r.readinessSummary(block, doc.Globals))
+		}
+		switch {
+		case r.opts.Trust:
+			fmt.Fprintln(r.stdout, "Approval: trusted")
+		case r.opts.Approvals != nil && r.opts.Approvals.IsApproved(HashBlockWithGlobals(block, doc.Globals)):
+			fmt.Fprintln(r.stdout, "Approval: approved")
+		default:
+			fmt.Fprintln(r.stdout, "Approval: not approved (would prompt)")
+		}
+
+		commands, unresolved := renderCommands(block, r.opts.EnvVars, doc.Globals)
+		fmt.Fprintln(r.stdout, "Commands:")
+		for j, cmd := range commands {
+			fmt.Fprintf(r.stdout, "  %d. %s\n", j+1, cmd)
+			for _, varName := range unresolved[j] {
+				if question, isPrompt := promptQuestion(varName, block.Variables, doc.Globals); isPrompt {
+					fmt.Fprintf(r.stdout, "     ? #%s is prompted at run time: %s\n", varName, question)
+				} else if captured[varName] {
+					fmt.Fprintf(r.stdout, "     ? #%s is captured at run time\n", varName)
+				} else {
+					fmt.Fprintf(r.stdout, "     ! #%s is not defined\n", varName)
+					unresolvedCount++
+				}
+			}
+
+			if varName, _, isCapture := ParseCapture(block.Commands[j]); isCapture {
+				captured[varName] = true
+			}
+		}
+		commandCount += len(commands)
+	}
+
+	fmt.Fprintf(r.stdout, "\nDry run: %d block(s), %d command(s), %d unresolved variable reference(s)\n",
+		len(order), commandCount, unresolvedCount)
+	return nil
+}
+
+// renderCommands substitutes env, global and block variables into each command of a block without prompting.
+// It also returns, per command, the names of variable references that are still unresolved.
+func renderCommands(block Block, envVars map[string]string, globals map[string]string) ([]string, [][]string) {
+	// Prompt answers are only known at run time, so leave their references in place
+	mergedVars := mergeVariables(envVars, withoutPrompts(globals), withoutPrompts(block.Variables))
+
+	var commands []string
+	var unresolved [][]string
+	for _, cmd := range block.Commands {
+		if varName, captureCmd, isCapture := ParseCapture(cmd); isCapture {
+			captureCmd = substituteVariables(captureCmd, mergedVars)
+			commands = append(commands, fmt.Sprintf("%s = #capture(%s)", varName, captureCmd))
+			unresolved = append(unresolved, unresolvedVariables(captureCmd))
+
+			// Later commands of the block see the captured value, not the block variable
+			delete(mergedVars, varName)
+			continue
+		}
+
+		cmd = substituteVariables(cmd, mergedVars)
+		commands = append(commands, cmd)
+		unresolved = append(unresolved, unresolvedVariables(cmd))
+	}
+
+	return commands, unresolved
+}
+
+// withoutPrompts returns a copy of variables without the prompt variables
+func withoutPrompts(variables map[string]string) map[string]string {
+	result := make(map[string]string)
+	for k, v := range variables {
+		if !strings.HasPrefix(v, "#PROMPT:") {
+			result[k] = v
+		}
+	}
+	return result
+}
+
+// unresolvedVariables returns the names of all #name references left in a substituted command
+func unresolvedVariables(cmd string) []string {
+	varUsageRegex := regexp.MustCompile(`#([a-zA-Z0-9_-]+)`)
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, matches := range varUsageRegex.FindAllStringSubmatch(cmd, -1) {
+		if !seen[matches[1]] {
+			seen[matches[1]] = true
+			names = append(names, matches[1])
+		}
+	}
+	return names
+}
+
+// promptQuestion returns the question of a prompt variable, looking at the given
+// variable layers from highest precedence to lowest
+func promptQuestion(varName string, layers ...map[string]string) (string, bool) {
+	for _, layer := range layers {
+		value, exists := layer[varName]
+		if !exists {
+			continue
+		}
+		if !strings.HasPrefix(value, "#PROMPT:") {
+			return "", false
+		}
+		return strings.TrimPrefix(value, "#PROMPT:"), true
+	}
+	return "", false
+}
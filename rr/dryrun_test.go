@@ -0,0 +1,101 @@
+package rr
+
+import (
+	"testing"
+)
+
+func TestRenderCommands_SubstitutesEnvAndBlockVariables(t *testing.T) {
+	block := Block{
+		Variables: map[string]string{
+			"env":      "staging",
+			"APP_NAME": "BlockApp",
+		},
+		Commands: []string{"deploy #APP_NAME --env #env --key #API_KEY"},
+	}
+	envVars := map[string]string{
+		"APP_NAME": "EnvApp",
+		"API_KEY":  "secret",
+	}
+
+	commands, unresolved := renderCommands(block, envVars, nil)
+
+	if len(commands) != 1 {
+		t.Fatalf("Expected 1 command, got %d", len(commands))
+	}
+	expected := "deploy BlockApp --env staging --key secret"
+	if commands[0] != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, commands[0])
+	}
+	if len(unresolved[0]) != 0 {
+		t.Errorf("Expected no unresolved variables, got %v", unresolved[0])
+	}
+}
+
+func TestRenderCommands_ReportsUnresolvedAndPrompts(t *testing.T) {
+	block := Block{
+		Variables: map[string]string{
+			"password": "#PROMPT:Enter password:",
+		},
+		Commands: []string{"login #user #password #user", "echo done"},
+	}
+
+	commands, unresolved := renderCommands(block, map[string]string{}, nil)
+
+	if commands[0] != "login #user #password #user" {
+		t.Errorf("Expected prompt and unknown references to be left in place, got '%s'", commands[0])
+	}
+	if len(unresolved[0]) != 2 || unresolved[0][0] != "user" || unresolved[0][1] != "password" {
+		t.Errorf("Expected unresolved [user password], got %v", unresolved[0])
+	}
+	if len(unresolved[1]) != 0 {
+		t.Errorf("Expected no unresolved variables in second command, got %v", unresolved[1])
+	}
+
+	if question, isPrompt := promptQuestion("password", block.Variables); !isPrompt || question != "Enter password:" {
+		t.Errorf("Expected 'password' to be a prompt, got '%s' %v", question, isPrompt)
+	}
+	if _, isPrompt := promptQuestion("user", block.Variables); isPrompt {
+		t.Error("Expected 'user' not to be a prompt")
+	}
+}
+
+func TestRenderCommands_DoesNotModifyBlock(t *testing.T) {
+	block := Block{
+		Variables: map[string]string{"name": "#PROMPT:Name?"},
+		Commands:  []string{"echo #name"},
+	}
+
+	renderCommands(block, nil, nil)
+
+	if block.Variables["name"] != "#PROMPT:Name?" {
+		t.Errorf("Expected prompt variable to be untouched, got '%s'", block.Variables["name"])
+	}
+}
+
+func TestRenderCommands_GlobalsAndCaptures(t *testing.T) {
+	block := Block{
+		Variables: map[string]string{"id": "block-value"},
+		Commands: []string{
+			"echo #api-url #id",
+			"id = #capture(docker run -d #image)",
+			"docker logs #id",
+		},
+	}
+	globals := map[string]string{"api-url": "http://localhost", "image": "app"}
+
+	commands, unresolved := renderCommands(block, nil, globals)
+
+	expected := []string{
+		"echo http://localhost block-value",
+		"id = #capture(docker run -d app)",
+		"docker logs #id",
+	}
+	for i, cmd := range expected {
+		if commands[i] != cmd {
+			t.Errorf("Expected command %d to be '%s', got '%s'", i+1, cmd, commands[i])
+		}
+	}
+	if len(unresolved[2]) != 1 || unresolved[2][0] != "id" {
+		t.Errorf("Expected captured reference to be left for run time, got %v", unresolved[2])
+	}
+}
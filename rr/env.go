@@ -0,0 +1,140 @@
+package rr
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// defaultInheritedEnv is inherited from the parent process when running with a clean environment
+// and no allow-list was given
+var defaultInheritedEnv = []string{"PATH", "HOME", "USER", "SHELL", "TERM", "LANG", "TMPDIR"}
+
+// commandEnvironment builds the environment for the commands of a block.
+// Block attributes (export-vars, clean-env, inherit-env) override the run options.
+// A nil result means the commands simply inherit the environment of the current process.
+func commandEnvironment(block Block, opts Options, variables map[string]string) []string {
+	exportVars := boolAttribute(block, "export-vars", opts.ExportVars)
+	cleanEnv := boolAttribute(block, "clean-env", opts.CleanEnv)
+	if !exportVars && !cleanEnv {
+		return nil
+	}
+
+	env := make(map[string]string)
+	if cleanEnv {
+		inherit := opts.InheritEnv
+		if value, exists := block.Attributes["inherit-env"]; exists {
+			inherit = splitList(value)
+		}
+		if len(inherit) == 0 {
+			inherit = defaultInheritedEnv
+		}
+		for _, name := range inherit {
+			if value, exists := os.LookupEnv(name); exists {
+				env[name] = value
+			}
+		}
+	} else {
+		for _, entry := range os.Environ() {
+			if name, value, found := strings.Cut(entry, "="); found {
+				env[name] = value
+			}
+		}
+	}
+
+	if exportVars {
+		for name, value := range variables {
+			// Names like my-var are fine for #substitution but can't be environment variables
+			if isEnvName(name) && !strings.HasPrefix(value, "#PROMPT:") {
+				env[name] = value
+			}
+		}
+	}
+
+	names := make([]string, 0, len(env))
+	for name := range env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		result = append(result, name+"="+env[name])
+	}
+	return result
+}
+
+// isEnvName reports whether name can be used as an environment variable name
+func isEnvName(name string) bool {
+	return regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`).MatchString(name)
+}
+
+// FindEnvFile finds the .env file in the specified directory or returns the provided path
+func FindEnvFile(envPath string, workDir string) (string, bool) {
+	// If env path is provided, use it directly
+	if envPath != "" {
+		absPath, err := filepath.Abs(envPath)
+		if err != nil {
+			return "", false
+		}
+		if _, err := os.Stat(absPath); err == nil {
+			return absPath, true
+		}
+		return "", false
+	}
+
+	envFilePath := filepath.Join(workDir, ".env")
+	if _, err := os.Stat(envFilePath); err == nil {
+		return envFilePath, true
+	}
+
+	return "", false
+}
+
+// LoadEnv loads environment variables from the .env file at envPath, or from the .env file
+// in workDir if envPath is empty. A missing or unreadable file results in an empty map.
+func LoadEnv(envPath string, workDir string) map[string]string {
+	envVars := make(map[string]string)
+
+	envFilePath, exists := FindEnvFile(envPath, workDir)
+	if !exists {
+		return envVars // Return empty map if no .env file found
+	}
+
+	content, err := os.ReadFile(envFilePath)
+	if err != nil {
+		// If we can't read the env file, return an empty map.
+		return envVars
+	}
+
+	lines := strings.Split(string(content), "\n")
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+
+		// Skip empty lines and comments
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// Parse KEY=VALUE format
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) == 2 {
+			key := strings.TrimSpace(parts[0])
+			value := strings.TrimSpace(parts[1])
+
+			// Remove quotes
+			if len(value) >= 2 {
+				if (strings.HasPrefix(value, `"`) && strings.HasSuffix(value, `"`)) ||
+					(strings.HasPrefix(value, `'`) && strings.HasSuffix(value, `'`)) {
+					value = value[1 : len(value)-1]
+				}
+			}
+
+			envVars[key] = value
+		}
+	}
+
+	return envVars
+}
@@ -0,0 +1,313 @@
+package rr
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFindEnvFile_CustomPath(t *testing.T) {
+	tempDir := t.TempDir()
+	customEnvPath := filepath.Join(tempDir, "custom.env")
+
+	err := os.WriteFile(customEnvPath, []byte("TEST=value"), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create custom .env file: %v", err)
+	}
+
+	path, exists := FindEnvFile(customEnvPath, tempDir)
+	if !exists {
+		t.Fatal("Expected custom .env file to be found")
+	}
+	if path != customEnvPath {
+		t.Errorf("Expected path '%s', got '%s'", customEnvPath, path)
+	}
+}
+
+func TestFindEnvFile_DefaultLocation(t *testing.T) {
+	tempDir := t.TempDir()
+	envPath := filepath.Join(tempDir, ".env")
+
+	err := os.WriteFile(envPath, []byte("TEST=value"), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create .env file: %v", err)
+	}
+
+	path, exists := FindEnvFile("", tempDir)
+	if !exists {
+		t.Fatal("Expected .env file to be found in work directory")
+	}
+	if path != envPath {
+		t.Errorf("Expected path '%s', got '%s'", envPath, path)
+	}
+}
+
+func TestFindEnvFile_NotFound(t *testing.T) {
+	tempDir := t.TempDir()
+
+	_, exists := FindEnvFile("", tempDir)
+	if exists {
+		t.Error("Expected .env file not to be found")
+	}
+}
+
+func TestLoadEnv_StandardFormat(t *testing.T) {
+	tempDir := t.TempDir()
+	envPath := filepath.Join(tempDir, ".env")
+
+	content := `APP_NAME=MyApp
+API_KEY=secret-123
+DEBUG=true
+DATABASE_URL=postgresql://localhost:5432/db`
+
+	err := os.WriteFile(envPath, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create .env file: %v", err)
+	}
+
+	envVars := LoadEnv("", tempDir)
+
+	if len(envVars) != 4 {
+		t.Fatalf("Expected 4 environment variables, got %d", len(envVars))
+	}
+
+	if envVars["APP_NAME"] != "MyApp" {
+		t.Errorf("Expected APP_NAME to be 'MyApp', got '%s'", envVars["APP_NAME"])
+	}
+	if envVars["API_KEY"] != "secret-123" {
+		t.Errorf("Expected API_KEY to be 'secret-123', got '%s'", envVars["API_KEY"])
+	}
+	if envVars["DEBUG"] != "true" {
+		t.Errorf("Expected DEBUG to be 'true', got '%s'", envVars["DEBUG"])
+	}
+	if envVars["DATABASE_URL"] != "postgresql://localhost:5432/db" {
+		t.Errorf("Expected DATABASE_URL to be 'postgresql://localhost:5432/db', got '%s'", envVars["DATABASE_URL"])
+	}
+}
+
+func TestLoadEnv_WithQuotes(t *testing.T) {
+	tempDir := t.TempDir()
+	envPath := filepath.Join(tempDir, ".env")
+
+	content := `APP_NAME="My App"
+API_KEY='secret-key-123'
+MESSAGE="Hello World"`
+
+	err := os.WriteFile(envPath, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create .env file: %v", err)
+	}
+
+	envVars := LoadEnv("", tempDir)
+
+	if envVars["APP_NAME"] != "My App" {
+		t.Errorf("Expected APP_NAME to be 'My App' (quotes removed), got '%s'", envVars["APP_NAME"])
+	}
+	if envVars["API_KEY"] != "secret-key-123" {
+		t.Errorf("Expected API_KEY to be 'secret-key-123' (quotes removed), got '%s'", envVars["API_KEY"])
+	}
+	if envVars["MESSAGE"] != "Hello World" {
+		t.Errorf("Expected MESSAGE to be 'Hello World' (quotes removed), got '%s'", envVars["MESSAGE"])
+	}
+}
+
+func TestLoadEnv_IgnoresComments(t *testing.T) {
+	tempDir := t.TempDir()
+	envPath := filepath.Join(tempDir, ".env")
+
+	content := `# This is a comment
+APP_NAME=MyApp
+# Another comment
+API_KEY=secret-123
+# Final comment`
+
+	err := os.WriteFile(envPath, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create .env file: %v", err)
+	}
+
+	envVars := LoadEnv("", tempDir)
+
+	if len(envVars) != 2 {
+		t.Fatalf("Expected 2 environment variables (comments ignored), got %d", len(envVars))
+	}
+
+	if envVars["APP_NAME"] != "MyApp" {
+		t.Error("Expected APP_NAME to be parsed")
+	}
+	if envVars["API_KEY"] != "secret-123" {
+		t.Error("Expected API_KEY to be parsed")
+	}
+}
+
+func TestLoadEnv_IgnoresEmptyLines(t *testing.T) {
+	tempDir := t.TempDir()
+	envPath := filepath.Join(tempDir, ".env")
+
+	content := `APP_NAME=MyApp
+
+API_KEY=secret-123
+
+DEBUG=true`
+
+	err := os.WriteFile(envPath, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create .env file: %v", err)
+	}
+
+	envVars := LoadEnv("", tempDir)
+
+	if len(envVars) != 3 {
+		t.Fatalf("Expected 3 environment variables (empty lines ignored), got %d", len(envVars))
+	}
+}
+
+func TestLoadEnv_WithCustomPath(t *testing.T) {
+	tempDir := t.TempDir()
+	customEnvPath := filepath.Join(tempDir, "custom.env")
+
+	content := `APP_NAME=MyApp
+API_KEY=secret-123`
+
+	err := os.WriteFile(customEnvPath, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create custom .env file: %v", err)
+	}
+
+	envVars := LoadEnv(customEnvPath, tempDir)
+
+	if len(envVars) != 2 {
+		t.Fatalf("Expected 2 environment variables, got %d", len(envVars))
+	}
+
+	if envVars["APP_NAME"] != "MyApp" {
+		t.Error("Expected APP_NAME to be loaded from custom path")
+	}
+}
+
+func TestLoadEnv_NonExistentFile(t *testing.T) {
+	tempDir := t.TempDir()
+
+	envVars := LoadEnv("", tempDir)
+
+	if len(envVars) != 0 {
+		t.Errorf("Expected empty map for non-existent .env file, got %d entries", len(envVars))
+	}
+}
+
+func TestLoadEnv_WithSpaces(t *testing.T) {
+	tempDir := t.TempDir()
+	envPath := filepath.Join(tempDir, ".env")
+
+	// Write content with spaces around equals signs - test that spaces are trimmed
+	content := "APP_NAME = MyApp\nAPI_KEY= secret-123\nDEBUG =true\n"
+
+	err := os.WriteFile(envPath, []byte(content), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create .env file: %v", err)
+	}
+
+	envVars := LoadEnv("", tempDir)
+
+	if len(envVars) != 3 {
+		t.Fatalf("Expected 3 environment variables, got %d", len(envVars))
+	}
+
+	if envVars["APP_NAME"] != "MyApp" {
+		t.Errorf("Expected APP_NAME to be 'MyApp' (spaces trimmed), got '%s'", envVars["APP_NAME"])
+	}
+	if envVars["API_KEY"] != "secret-123" {
+		t.Errorf("Expected API_KEY to be 'secret-123' (spaces trimmed), got '%s'", envVars["API_KEY"])
+	}
+	if envVars["DEBUG"] != "true" {
+		t.Errorf("Expected DEBUG to be 'true' (spaces trimmed), got '%s'", envVars["DEBUG"])
+	}
+}
+
+// envMap turns a KEY=VALUE list into a map for easier assertions
+func envMap(env []string) map[string]string {
+	result := make(map[string]string)
+	for _, entry := range env {
+		name, value, _ := strings.Cut(entry, "=")
+		result[name] = value
+	}
+	return result
+}
+
+func TestCommandEnvironment_DefaultInherits(t *testing.T) {
+	env := commandEnvironment(Block{}, Options{}, map[string]string{"DATABASE_URL": "db"})
+	if env != nil {
+		t.Errorf("Expected nil environment when nothing is exported, got %v", env)
+	}
+}
+
+func TestCommandEnvironment_ExportVars(t *testing.T) {
+	t.Setenv("RR_PARENT_VAR", "parent")
+
+	variables := map[string]string{
+		"DATABASE_URL": "postgres://localhost/db",
+		"my-var":       "not a valid env name",
+		"PASSWORD":     "#PROMPT:Password?",
+	}
+	env := envMap(commandEnvironment(Block{}, Options{ExportVars: true}, variables))
+
+	if env["DATABASE_URL"] != "postgres://localhost/db" {
+		t.Errorf("Expected DATABASE_URL to be exported, got '%s'", env["DATABASE_URL"])
+	}
+	if _, exists := env["my-var"]; exists {
+		t.Error("Expected invalid variable name not to be exported")
+	}
+	if _, exists := env["PASSWORD"]; exists {
+		t.Error("Expected unanswered prompt not to be exported")
+	}
+	if env["RR_PARENT_VAR"] != "parent" {
+		t.Error("Expected parent environment to be inherited")
+	}
+}
+
+func TestCommandEnvironment_CleanEnv(t *testing.T) {
+	t.Setenv("RR_PARENT_VAR", "parent")
+	t.Setenv("RR_ALLOWED_VAR", "allowed")
+
+	opts := Options{CleanEnv: true, InheritEnv: []string{"RR_ALLOWED_VAR"}}
+	env := envMap(commandEnvironment(Block{}, opts, map[string]string{"APP": "app"}))
+
+	if len(env) != 1 || env["RR_ALLOWED_VAR"] != "allowed" {
+		t.Errorf("Expected only the allow-listed variable, got %v", env)
+	}
+}
+
+func TestCommandEnvironment_CleanEnvDefaultAllowList(t *testing.T) {
+	t.Setenv("PATH", "/usr/bin")
+	t.Setenv("RR_PARENT_VAR", "parent")
+
+	env := envMap(commandEnvironment(Block{}, Options{CleanEnv: true}, nil))
+
+	if env["PATH"] != "/usr/bin" {
+		t.Error("Expected PATH to be inherited by default")
+	}
+	if _, exists := env["RR_PARENT_VAR"]; exists {
+		t.Error("Expected variables outside the allow-list to be dropped")
+	}
+}
+
+func TestCommandEnvironment_BlockAttributesOverrideOptions(t *testing.T) {
+	t.Setenv("RR_ALLOWED_VAR", "allowed")
+
+	block := Block{Attributes: map[string]string{
+		"export-vars": "true",
+		"clean-env":   "true",
+		"inherit-env": "RR_ALLOWED_VAR",
+	}}
+	env := envMap(commandEnvironment(block, Options{}, map[string]string{"APP": "app"}))
+
+	if len(env) != 2 || env["APP"] != "app" || env["RR_ALLOWED_VAR"] != "allowed" {
+		t.Errorf("Expected APP and RR_ALLOWED_VAR only, got %v", env)
+	}
+
+	block = Block{Attributes: map[string]string{"export-vars": "false"}}
+	if env := commandEnvironment(block, Options{ExportVars: true}, map[string]string{"APP": "app"}); env != nil {
+		t.Errorf("Expected block to opt out of exporting, got %v", env)
+	}
+}
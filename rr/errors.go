@@ -0,0 +1,246 @@
+package rr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProjectPathError reports a project directory that can't be resolved or doesn't exist
+type ProjectPathError struct {
+	Path string
+	Err  error
+}
+
+func (e *ProjectPathError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("invalid project path %s: %v", e.Path, e.Err)
+	}
+	return fmt.Sprintf("project path does not exist: %s", e.Path)
+}
+
+func (e *ProjectPathError) Unwrap() error { return e.Err }
+
+// ReadmeNotFoundError reports a project directory without a readable readme
+type ReadmeNotFoundError struct {
+	Dir string
+	Err error // set when a readme exists but can't be read
+}
+
+func (e *ReadmeNotFoundError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("error reading readme file in %s: %v", e.Dir, e.Err)
+	}
+	return fmt.Sprintf("no readme found in directory %s", e.Dir)
+}
+
+func (e *ReadmeNotFoundError) Unwrap() error { return e.Err }
+
+// InvalidSelectionError reports block selection arguments or flags that can't be used
+type InvalidSelectionError struct {
+	Err error
+}
+
+func (e *InvalidSelectionError) Error() string {
+	return fmt.Sprintf("error selecting blocks: %v", e.Err)
+}
+
+func (e *InvalidSelectionError) Unwrap() error { return e.Err }
+
+// DependencyError reports a needs attribute that doesn't name exactly one block
+type DependencyError struct {
+	Index  int    // 1-based number of the block with the needs attribute
+	Name   string // name of that block, may be empty
+	Need   string // the block name it needs
+	Reason string
+}
+
+func (e *DependencyError) Error() string {
+	name := ""
+	if e.Name != "" {
+		name = " (" + e.Name + ")"
+	}
+	return fmt.Sprintf("block %d%s needs %q, but %s", e.Index, name, e.Need, e.Reason)
+}
+
+// AttributeError reports a block attribute whose value can't be used
+type AttributeError struct {
+	Index     int    // 1-based number of the block
+	Name      string // name of the block, may be empty
+	Attribute string
+	Value     string
+	Reason    string
+}
+
+func (e *AttributeError) Error() string {
+	name := ""
+	if e.Name != "" {
+		name = " (" + e.Name + ")"
+	}
+	return fmt.Sprintf("block %d%s has %s=%q, which %s", e.Index, name, e.Attribute, e.Value, e.Reason)
+}
+
+// CycleError reports blocks that need each other, directly or through other blocks
+type CycleError struct {
+	Blocks []string // names of the blocks in the cycle, starting and ending with the same block
+}
+
+func (e *CycleError) Error() string {
+	return fmt.Sprintf("dependency cycle between blocks: %s", strings.Join(e.Blocks, " -> "))
+}
+
+// CommandError reports a command of a block that didn't succeed
+type CommandError struct {
+	Command  string // command after variable substitution
+	ExitCode int    // exit code of the command, or -1 if it didn't exit normally
+	Err      error
+}
+
+func (e *CommandError) Error() string {
+	return fmt.Sprintf("command failed: %s: %v", e.Command, e.Err)
+}
+
+func (e *CommandError) Unwrap() error { return e.Err }
+
+// TimeoutError reports a command that was killed because it ran into a time limit
+type TimeoutError struct {
+	Limit time.Duration
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("timed out after %s", e.Limit)
+}
+
+func (e *TimeoutError) Unwrap() error { return context.DeadlineExceeded }
+
+// NotReadyError reports a background block whose readiness checks didn't all pass in time
+type NotReadyError struct {
+	Limit   time.Duration
+	Pending []string // the checks that didn't pass, e.g. "port localhost:5432"
+}
+
+func (e *NotReadyError) Error() string {
+	return fmt.Sprintf("not ready after %s, still waiting for %s", e.Limit, strings.Join(e.Pending, ", "))
+}
+
+// OutputMismatchError reports a block whose output isn't what its expect: section or output fence says
+type OutputMismatchError struct {
+	Match    string // how the output was compared: exact, contains or regex
+	Expected string
+	Actual   string
+}
+
+func (e *OutputMismatchError) Error() string {
+	switch e.Match {
+	case "contains":
+		return "output doesn't contain the expected output"
+	case "regex":
+		return "output doesn't match the expected pattern"
+	}
+	return "output doesn't match the expected output"
+}
+
+// ExitCodeMismatchError reports a block that didn't exit with the code its expect-exit attribute says
+type ExitCodeMismatchError struct {
+	Expected int
+	Actual   int
+}
+
+func (e *ExitCodeMismatchError) Error() string {
+	return fmt.Sprintf("expected exit code %d, got %d", e.Expected, e.Actual)
+}
+
+// InterruptedError reports a run that was stopped with Runner.Interrupt, or with Ctrl-C at the terminal
+type InterruptedError struct {
+	Signal os.Signal
+}
+
+func (e *InterruptedError) Error() string {
+	return fmt.Sprintf("run interrupted (%v)", e.Signal)
+}
+
+// InterpreterError reports a block whose interpreter, e.g. bash or python3, isn't installed
+type InterpreterError struct {
+	Interpreter string
+	Err         error
+}
+
+func (e *InterpreterError) Error() string {
+	return fmt.Sprintf("%s is needed to run this block, but it is not installed or not on the PATH", e.Interpreter)
+}
+
+func (e *InterpreterError) Unwrap() error { return e.Err }
+
+// BlockFailedError reports a block that stopped before all of its commands ran successfully
+type BlockFailedError struct {
+	Index    int    // 1-based block number
+	Name     string // block name, may be empty
+	Command  string // failing command, empty if the block failed before running one
+	ExitCode int    // exit code of the failing command, or -1
+	Err      error
+}
+
+func (e *BlockFailedError) Error() string {
+	name := ""
+	if e.Name != "" {
+		name = " (" + e.Name + ")"
+	}
+	return fmt.Sprintf("block %d%s failed: %v", e.Index, name, e.Err)
+}
+
+func (e *BlockFailedError) Unwrap() error { return e.Err }
+
+// newBlockFailedError wraps the error returned by runBlock
+func newBlockFailedError(blockNum int, block Block, err error) *BlockFailedError {
+	blockErr := &BlockFailedError{Index: blockNum, Name: block.Name, ExitCode: -1, Err: err}
+
+	var cmdErr *CommandError
+	if errors.As(err, &cmdErr) {
+		blockErr.Command = cmdErr.Command
+		blockErr.ExitCode = cmdErr.ExitCode
+	}
+	return blockErr
+}
+
+// RunFailedError reports a run in which more than one block failed
+type RunFailedError struct {
+	Failures []*BlockFailedError
+}
+
+func (e *RunFailedError) Error() string {
+	blocks := make([]string, 0, len(e.Failures))
+	for _, failure := range e.Failures {
+		block := strconv.Itoa(failure.Index)
+		if failure.Name != "" {
+			block += " (" + failure.Name + ")"
+		}
+		blocks = append(blocks, block)
+	}
+	return fmt.Sprintf("%d blocks failed: %s", len(e.Failures), strings.Join(blocks, ", "))
+}
+
+func (e *RunFailedError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Failures))
+	for _, failure := range e.Failures {
+		errs = append(errs, failure)
+	}
+	return errs
+}
+
+// exitStatus returns the exit code carried by an error from the shell session, or -1 if there is none
+func exitStatus(err error) int {
+	var statusErr *exitStatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.code
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
@@ -0,0 +1,33 @@
+package rr
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNewBlockFailedError(t *testing.T) {
+	cmdErr := &CommandError{Command: "make build", ExitCode: 2, Err: &exitStatusError{code: 2}}
+
+	blockErr := newBlockFailedError(3, Block{Name: "Build"}, cmdErr)
+
+	if blockErr.Index != 3 || blockErr.Name != "Build" {
+		t.Errorf("Unexpected block details: %+v", blockErr)
+	}
+	if blockErr.Command != "make build" || blockErr.ExitCode != 2 {
+		t.Errorf("Expected command details to be copied, got %+v", blockErr)
+	}
+	if !errors.Is(blockErr, cmdErr) {
+		t.Error("Expected block error to wrap the command error")
+	}
+	if blockErr.Error() != "block 3 (Build) failed: command failed: make build: exit status 2" {
+		t.Errorf("Unexpected error message: %s", blockErr.Error())
+	}
+}
+
+func TestNewBlockFailedError_WithoutCommand(t *testing.T) {
+	blockErr := newBlockFailedError(1, Block{}, errors.New("error reading input for prompt"))
+
+	if blockErr.Command != "" || blockErr.ExitCode != -1 {
+		t.Errorf("Expected no command details, got %+v", blockErr)
+	}
+}
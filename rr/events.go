@@ -0,0 +1,142 @@
+package rr
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// EventType names what happened in a run, see Event
+type EventType string
+
+const (
+	EventRunStart     EventType = "run_start"     // the run starts
+	EventApproval     EventType = "approval"      // a block was approved or declined
+	EventBlockStart   EventType = "block_start"   // a block starts running
+	EventCommandStart EventType = "command_start" // a command of a block starts, with its variables substituted
+	EventOutput       EventType = "output"        // a command printed something on stdout or stderr
+	EventCommandEnd   EventType = "command_end"   // a command finished
+	EventBlockEnd     EventType = "block_end"     // the outcome of a selected block, whether it ran or not
+	EventRunEnd       EventType = "run_end"       // the run finished
+)
+
+// Approval decisions of EventApproval
+const (
+	DecisionTrusted    = "trusted"    // every block runs, see Options.Trust
+	DecisionRemembered = "remembered" // the block was approved before
+	DecisionApproved   = "approved"
+	DecisionDeclined   = "declined"
+)
+
+// Event is something that happened in a run, for tools that follow a run as it goes, see Options.Events.
+// Only the fields that apply to the type of event are set.
+type Event struct {
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+
+	Block  int    `json:"block,omitempty"`  // 1-based block number
+	Name   string `json:"name,omitempty"`   // block name
+	Blocks int    `json:"blocks,omitempty"` // run_start: how many blocks the readme has
+
+	Decision string `json:"decision,omitempty"` // approval: see the Decision constants
+	Command  string `json:"command,omitempty"`  // command_start and command_end
+	Stream   string `json:"stream,omitempty"`   // output: stdout or stderr
+	Data     string `json:"data,omitempty"`     // output: what was printed
+
+	ExitCode   *int   `json:"exit_code,omitempty"`   // command_end: -1 if the command didn't exit on its own, e.g. after a timeout
+	DurationMS *int64 `json:"duration_ms,omitempty"` // command_end, block_end and run_end
+	Status     string `json:"status,omitempty"`      // block_end: see BlockStatus; run_end: succeeded, failed or interrupted
+	Error      string `json:"error,omitempty"`       // block_end and run_end of a failure
+}
+
+// JSONEvents returns an Options.Events function that writes every event to w as a line of JSON
+func JSONEvents(w io.Writer) func(Event) {
+	var mu sync.Mutex
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	return func(event Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		_ = encoder.Encode(event)
+	}
+}
+
+// emit passes an event to Options.Events, one event at a time
+func (r *Runner) emit(event Event) {
+	if r.opts.Events == nil {
+		return
+	}
+	event.Time = time.Now()
+	r.eventsMu.Lock()
+	defer r.eventsMu.Unlock()
+	r.opts.Events(event)
+}
+
+// startCommand emits the start of a command and returns a function that emits its end
+func (r *Runner) startCommand(block int, command string) func(err error) {
+	r.emit(Event{Type: EventCommandStart, Block: block, Command: command})
+	start := time.Now()
+	return func(err error) {
+		exitCode := 0
+		if err != nil {
+			exitCode = exitStatus(err)
+		}
+		r.emit(Event{Type: EventCommandEnd, Block: block, Command: command, ExitCode: &exitCode, DurationMS: milliseconds(time.Since(start))})
+	}
+}
+
+// eventOutput makes the output of a block's commands also go to output events, when there are events
+func (r *Runner) eventOutput(block int, stdout, stderr io.Writer) (io.Writer, io.Writer) {
+	if r.opts.Events == nil {
+		return stdout, stderr
+	}
+	return io.MultiWriter(stdout, eventWriter{r: r, block: block, stream: "stdout"}),
+		io.MultiWriter(stderr, eventWriter{r: r, block: block, stream: "stderr"})
+}
+
+// eventWriter turns what is written to it into output events
+type eventWriter struct {
+	r      *Runner
+	block  int
+	stream string
+}
+
+func (w eventWriter) Write(p []byte) (int, error) {
+	w.r.emit(Event{Type: EventOutput, Block: w.block, Stream: w.stream, Data: string(p)})
+	return len(p), nil
+}
+
+// blockEndEvent describes the outcome of a block
+func blockEndEvent(result BlockResult) Event {
+	event := Event{Type: EventBlockEnd, Block: result.Index, Name: result.Name, Status: string(result.Status)}
+	if result.Duration > 0 {
+		event.DurationMS = milliseconds(result.Duration)
+	}
+	if result.Err != nil {
+		event.Error = result.Err.Error()
+	}
+	return event
+}
+
+// runEndEvent describes how a run ended
+func runEndEvent(err error, duration time.Duration) Event {
+	event := Event{Type: EventRunEnd, Status: "succeeded", DurationMS: milliseconds(duration)}
+	var interruptedErr *InterruptedError
+	switch {
+	case errors.As(err, &interruptedErr):
+		event.Status = "interrupted"
+		event.Error = err.Error()
+	case err != nil:
+		event.Status = "failed"
+		event.Error = err.Error()
+	}
+	return event
+}
+
+// milliseconds converts a duration for an event
+func milliseconds(d time.Duration) *int64 {
+	ms := d.Milliseconds()
+	return &ms
+}
@@ -0,0 +1,76 @@
+package rr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestRun_Events(t *testing.T) {
+	doc := Parse("<!-- RR[Greet]\necho hello #who\necho oops >&2\n-->\n<!-- RR[Declined]\ntrue\n-->\n<!-- RR[Fail]\nexit 3\n-->")
+	doc.Globals = map[string]string{"who": "world"}
+
+	var events []Event
+	confirm := func(block Block, blockNum, totalBlocks int) bool { return block.Name != "Declined" }
+	runner := NewRunner(Options{Stdout: &bytes.Buffer{}, Confirm: confirm, KeepGoing: true, Events: func(event Event) {
+		events = append(events, event)
+	}})
+	runner.Run(context.Background(), doc)
+
+	var types []string
+	for _, event := range events {
+		types = append(types, string(event.Type))
+		if event.Time.IsZero() {
+			t.Errorf("Expected every event to have a time, got %+v", event)
+		}
+	}
+	expected := "run_start approval block_start command_start output command_end command_start output command_end block_end " +
+		"approval block_end approval block_start command_start command_end block_end run_end"
+	if strings.Join(types, " ") != expected {
+		t.Fatalf("Unexpected events:\n%s\nexpected:\n%s", strings.Join(types, " "), expected)
+	}
+
+	if events[1].Decision != DecisionApproved || events[10].Decision != DecisionDeclined {
+		t.Errorf("Expected the approval decisions, got %q and %q", events[1].Decision, events[10].Decision)
+	}
+	if events[3].Command != "echo hello world" || events[3].Block != 1 {
+		t.Errorf("Expected the substituted command of block 1, got %+v", events[3])
+	}
+	if events[4].Stream != "stdout" || events[4].Data != "hello world\n" || events[7].Stream != "stderr" {
+		t.Errorf("Expected output events for both streams, got %+v and %+v", events[4], events[7])
+	}
+	if end := events[15]; end.ExitCode == nil || *end.ExitCode != 3 || end.DurationMS == nil {
+		t.Errorf("Expected the failed command's exit code and duration, got %+v", end)
+	}
+	if events[11].Status != string(StatusSkipped) || events[16].Status != string(StatusFailed) || events[16].Error == "" {
+		t.Errorf("Expected the block outcomes, got %+v and %+v", events[11], events[16])
+	}
+	if last := events[len(events)-1]; last.Status != "failed" || last.Error == "" {
+		t.Errorf("Expected the run to end as failed, got %+v", last)
+	}
+}
+
+func TestJSONEvents(t *testing.T) {
+	var out bytes.Buffer
+	events := JSONEvents(&out)
+	exitCode := 0
+	events(Event{Type: EventCommandEnd, Block: 2, Command: "test -f a && echo <ok>", ExitCode: &exitCode})
+	events(Event{Type: EventRunEnd, Status: "succeeded"})
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected one line per event, got %q", out.String())
+	}
+	var decoded map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &decoded); err != nil {
+		t.Fatalf("Expected valid JSON, got %v", err)
+	}
+	if decoded["type"] != "command_end" || decoded["command"] != "test -f a && echo <ok>" || decoded["exit_code"] != float64(0) {
+		t.Errorf("Unexpected event: %s", lines[0])
+	}
+	if _, hasStream := decoded["stream"]; hasStream {
+		t.Errorf("Expected fields that don't apply to be left out, got %s", lines[0])
+	}
+}
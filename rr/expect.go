@@ -0,0 +1,82 @@
+package rr
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// expectation is what a block's commands are expected to print and exit with. The output comes
+// from an expect: section or an output fence, the rest from the expect-match and expect-exit attributes.
+type expectation struct {
+	output    string
+	hasOutput bool
+	match     string // exact, contains or regex
+	exitCode  int
+}
+
+// blockExpectation returns the expectation of a block, and false if nothing is expected of it
+func blockExpectation(block Block) (expectation, bool) {
+	expect := expectation{
+		output:    block.ExpectedOutput,
+		hasOutput: block.HasExpectedOutput,
+		match:     block.Attributes["expect-match"],
+		exitCode:  intAttribute(block, "expect-exit", 0),
+	}
+	if expect.match == "" {
+		expect.match = "exact"
+	}
+	_, expectsExit := block.Attributes["expect-exit"]
+	return expect, expect.hasOutput || expectsExit
+}
+
+// checkOutput compares the output of a block's commands with the expected output. Line endings and
+// whitespace at the end of lines and of the output are ignored.
+func (e expectation) checkOutput(output string) error {
+	if !e.hasOutput {
+		return nil
+	}
+	expected, actual := normalizeOutput(e.output), normalizeOutput(output)
+
+	var matches bool
+	switch e.match {
+	case "exact":
+		matches = actual == expected
+	case "contains":
+		matches = strings.Contains(actual, expected)
+	case "regex":
+		// The pattern has to match the whole output, and . matches line breaks too
+		re, err := regexp.Compile(`(?s)\A(?:` + expected + `)\z`)
+		if err != nil {
+			return fmt.Errorf("invalid expected output pattern: %v", err)
+		}
+		matches = re.MatchString(actual)
+	default:
+		return fmt.Errorf("unknown expect-match %q, use exact, contains or regex", e.match)
+	}
+
+	if !matches {
+		return &OutputMismatchError{Match: e.match, Expected: expected, Actual: actual}
+	}
+	return nil
+}
+
+// normalizeOutput removes carriage returns and trailing whitespace, so output compares the same on every platform
+func normalizeOutput(output string) string {
+	lines := strings.Split(strings.ReplaceAll(output, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	return strings.TrimRight(strings.Join(lines, "\n"), "\n")
+}
+
+// splitExpect splits the lines of a block at an expect: line into the block's content and its
+// expected output
+func splitExpect(lines []string) ([]string, string, bool) {
+	for i, line := range lines {
+		if strings.TrimSpace(line) == "expect:" {
+			return lines[:i], dedent(lines[i+1:]), true
+		}
+	}
+	return lines, "", false
+}
@@ -0,0 +1,111 @@
+package rr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestExpectation_CheckOutput(t *testing.T) {
+	tests := []struct {
+		match    string
+		expected string
+		output   string
+		matches  bool
+	}{
+		{"exact", "hello\nworld", "hello  \r\nworld\n\n", true},
+		{"exact", "hello", "hello world", false},
+		{"contains", "world", "hello\nworld\n", true},
+		{"contains", "moon", "hello world", false},
+		{"regex", `version \d+\.\d+`, "version 1.24\n", true},
+		{"regex", `\d+`, "version 1", false},
+		{"regex", `started.*done`, "started\nworking\ndone", true},
+	}
+
+	for _, tt := range tests {
+		expect := expectation{output: tt.expected, hasOutput: true, match: tt.match}
+		err := expect.checkOutput(tt.output)
+		var mismatch *OutputMismatchError
+		if tt.matches && err != nil || !tt.matches && !errors.As(err, &mismatch) {
+			t.Errorf("checkOutput(%s %q, %q) = %v, expected match %v", tt.match, tt.expected, tt.output, err, tt.matches)
+		}
+	}
+
+	if err := (expectation{output: "(", hasOutput: true, match: "regex"}).checkOutput(""); err == nil {
+		t.Error("Expected an invalid pattern to be reported")
+	}
+	if err := (expectation{hasOutput: true, match: "fuzzy"}).checkOutput(""); err == nil {
+		t.Error("Expected an unknown expect-match to be reported")
+	}
+}
+
+func TestParse_ExpectSection(t *testing.T) {
+	doc := Parse("<!-- RR[Greet]{expect-match=contains}\necho hello\nexpect:\n  hello\n-->")
+
+	block := doc.Blocks[0]
+	if len(block.Commands) != 1 || block.Commands[0] != "echo hello" {
+		t.Errorf("Expected the expect section not to be a command, got %q", block.Commands)
+	}
+	if !block.HasExpectedOutput || block.ExpectedOutput != "hello" {
+		t.Errorf("Expected output 'hello', got %q (%v)", block.ExpectedOutput, block.HasExpectedOutput)
+	}
+}
+
+func TestParse_OutputFence(t *testing.T) {
+	content := "```bash rr name=Greet\necho hello\n```\n\n```output\nhello\n```\n\n" +
+		"Some text\n\n```output\nnot expected\n```\n\n" +
+		"<!-- RR[Other]\necho other\n-->\n```output\nother\n```\n"
+
+	blocks := Parse(content).Blocks
+	if len(blocks) != 2 {
+		t.Fatalf("Expected 2 blocks, got %d", len(blocks))
+	}
+	if !blocks[0].HasExpectedOutput || blocks[0].ExpectedOutput != "hello" {
+		t.Errorf("Expected the output fence right after the block to be its expected output, got %q", blocks[0].ExpectedOutput)
+	}
+	if !blocks[1].HasExpectedOutput || blocks[1].ExpectedOutput != "other" {
+		t.Errorf("Expected the output fence after the comment block to be its expected output, got %q", blocks[1].ExpectedOutput)
+	}
+}
+
+func TestRun_ExpectedOutputMismatch(t *testing.T) {
+	doc := Parse("```bash rr name=Greet\necho hello\necho world\n```\n\n```output\nhello\nmoon\n```\n")
+
+	var stdout bytes.Buffer
+	runner := NewRunner(Options{Stdout: &stdout, Trust: true})
+	err := runner.Run(context.Background(), doc)
+
+	var mismatch *OutputMismatchError
+	if !errors.As(err, &mismatch) {
+		t.Fatalf("Expected an OutputMismatchError, got %v", err)
+	}
+	output := stdout.String()
+	if !strings.Contains(output, "world\n") {
+		t.Errorf("Expected the output to be streamed as well, got %q", output)
+	}
+	if !strings.Contains(output, "--- expected\n+++ actual\n@@ -1,2 +1,2 @@\n hello\n-moon\n+world\n") {
+		t.Errorf("Expected a unified diff of the output, got %q", output)
+	}
+}
+
+func TestRun_ExpectedOutputAndExitCode(t *testing.T) {
+	doc := Parse("<!-- RR[Check]{expect-exit=3}\necho checking\nsh -c 'exit 3'\necho never\nexpect:\nchecking\n-->\n" +
+		"<!-- RR[Wrong]{expect-exit=3}\ntrue\n-->")
+
+	var stdout bytes.Buffer
+	runner := NewRunner(Options{Stdout: &stdout, Trust: true, KeepGoing: true})
+	err := runner.Run(context.Background(), doc)
+
+	if statuses := resultStatuses(runner); statuses[0] != StatusSucceeded || statuses[1] != StatusFailed {
+		t.Errorf("Expected [succeeded failed], got %v", statuses)
+	}
+	var exitErr *ExitCodeMismatchError
+	if !errors.As(err, &exitErr) || exitErr.Expected != 3 || exitErr.Actual != 0 {
+		t.Errorf("Expected an ExitCodeMismatchError, got %v", err)
+	}
+	if strings.Contains(stdout.String(), "never") {
+		t.Errorf("Expected the block to end at the expected exit code, got %q", stdout.String())
+	}
+}
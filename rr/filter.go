@@ -0,0 +1,131 @@
+package rr
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// blockRange is an inclusive range of 1-based block numbers
+type blockRange struct {
+	start int
+	end   int
+}
+
+// Filter decides which blocks of a readme take part in a run.
+// A block is selected when it matches any of the ranges, only patterns or tags
+// (or when none of those were given) and does not match a skip pattern.
+// The zero Filter selects every block.
+type Filter struct {
+	ranges []blockRange
+	only   []string
+	skip   []string
+	tags   []string
+	tests  bool // only blocks marked with the test attribute
+}
+
+// NewFilter builds a filter from block numbers or ranges (e.g. "3" or "3-5"),
+// glob patterns matched against block names and tags
+func NewFilter(ranges []string, only []string, skip []string, tags []string) (Filter, error) {
+	filter := Filter{only: only, skip: skip, tags: tags}
+
+	for _, arg := range ranges {
+		r, err := parseBlockRange(arg)
+		if err != nil {
+			return Filter{}, &InvalidSelectionError{Err: err}
+		}
+		filter.ranges = append(filter.ranges, r)
+	}
+
+	// Validate patterns up front so a typo doesn't silently match nothing
+	for _, pattern := range append(append([]string{}, only...), skip...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return Filter{}, &InvalidSelectionError{Err: fmt.Errorf("invalid block name pattern %q: %v", pattern, err)}
+		}
+	}
+
+	return filter, nil
+}
+
+// parseBlockRange parses a single block number or an inclusive range of block numbers
+func parseBlockRange(arg string) (blockRange, error) {
+	startText, endText, isRange := strings.Cut(arg, "-")
+
+	start, err := strconv.Atoi(strings.TrimSpace(startText))
+	if err != nil || start < 1 {
+		return blockRange{}, fmt.Errorf("invalid block number or range %q", arg)
+	}
+	if !isRange {
+		return blockRange{start: start, end: start}, nil
+	}
+
+	end, err := strconv.Atoi(strings.TrimSpace(endText))
+	if err != nil || end < start {
+		return blockRange{}, fmt.Errorf("invalid block number or range %q", arg)
+	}
+
+	return blockRange{start: start, end: end}, nil
+}
+
+// Matches reports whether the block with the given 1-based number is selected
+func (f Filter) Matches(blockNum int, block Block) bool {
+	if f.Skips(block) {
+		return false
+	}
+	if f.tests && !boolAttribute(block, "test", false) {
+		return false
+	}
+
+	if len(f.ranges) == 0 && len(f.only) == 0 && len(f.tags) == 0 {
+		return true
+	}
+
+	for _, r := range f.ranges {
+		if blockNum >= r.start && blockNum <= r.end {
+			return true
+		}
+	}
+
+	for _, pattern := range f.only {
+		if matchName(pattern, block.Name) {
+			return true
+		}
+	}
+
+	for _, tag := range f.tags {
+		for _, blockTag := range block.Tags {
+			if tag == blockTag {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Tests returns a copy of the filter that only selects the blocks marked with the test attribute.
+// The blocks they need still run.
+func (f Filter) Tests() Filter {
+	f.tests = true
+	return f
+}
+
+// Skips reports whether the block matches a skip pattern
+func (f Filter) Skips(block Block) bool {
+	for _, pattern := range f.skip {
+		if matchName(pattern, block.Name) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchName matches a block name against a glob pattern. Unnamed blocks never match.
+func matchName(pattern string, name string) bool {
+	if name == "" {
+		return false
+	}
+	matched, _ := path.Match(pattern, name)
+	return matched
+}
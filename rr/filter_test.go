@@ -0,0 +1,131 @@
+package rr
+
+import (
+	"testing"
+)
+
+func TestParseBlockRange(t *testing.T) {
+	r, err := parseBlockRange("3-5")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if r.start != 3 || r.end != 5 {
+		t.Errorf("Expected range 3-5, got %d-%d", r.start, r.end)
+	}
+
+	r, err = parseBlockRange("7")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if r.start != 7 || r.end != 7 {
+		t.Errorf("Expected range 7-7, got %d-%d", r.start, r.end)
+	}
+}
+
+func TestParseBlockRange_Invalid(t *testing.T) {
+	for _, arg := range []string{"", "0", "abc", "5-3", "3-", "-3"} {
+		if _, err := parseBlockRange(arg); err == nil {
+			t.Errorf("Expected error for range %q", arg)
+		}
+	}
+}
+
+func TestFilter_NoCriteriaMatchesAll(t *testing.T) {
+	filter, err := NewFilter(nil, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !filter.Matches(1, Block{Name: "Anything"}) {
+		t.Error("Expected named block to match empty filter")
+	}
+	if !filter.Matches(2, Block{}) {
+		t.Error("Expected unnamed block to match empty filter")
+	}
+}
+
+func TestFilter_OnlyAndSkip(t *testing.T) {
+	filter, err := NewFilter(nil, []string{"Seed DB", "Docker*"}, []string{"Docker Cleanup"}, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := map[string]bool{
+		"Seed DB":        true,
+		"Docker Build":   true,
+		"Docker Cleanup": false,
+		"Run Tests":      false,
+	}
+	for name, expected := range tests {
+		if filter.Matches(1, Block{Name: name}) != expected {
+			t.Errorf("Expected match for '%s' to be %v", name, expected)
+		}
+	}
+}
+
+func TestFilter_Ranges(t *testing.T) {
+	filter, err := NewFilter([]string{"2", "4-5"}, nil, nil, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []bool{false, true, false, true, true, false}
+	for i, want := range expected {
+		if filter.Matches(i+1, Block{}) != want {
+			t.Errorf("Expected block %d match to be %v", i+1, want)
+		}
+	}
+}
+
+func TestFilter_Tags(t *testing.T) {
+	filter, err := NewFilter(nil, nil, nil, []string{"db"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if !filter.Matches(1, Block{Name: "Seed", Tags: []string{"db", "slow"}}) {
+		t.Error("Expected block tagged 'db' to match")
+	}
+	if filter.Matches(2, Block{Name: "Lint", Tags: []string{"ci"}}) {
+		t.Error("Expected block without 'db' tag not to match")
+	}
+}
+
+func TestFilter_SkipUnnamedBlocks(t *testing.T) {
+	filter, err := NewFilter(nil, nil, []string{"*"}, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if filter.Matches(1, Block{Name: "Named"}) {
+		t.Error("Expected named block to be skipped")
+	}
+	if !filter.Matches(2, Block{}) {
+		t.Error("Expected unnamed block not to match a skip pattern")
+	}
+}
+
+func TestNewBlockFilter_InvalidPattern(t *testing.T) {
+	if _, err := NewFilter(nil, []string{"[abc"}, nil, nil); err == nil {
+		t.Error("Expected error for invalid glob pattern")
+	}
+}
+
+func TestFilter_Tests(t *testing.T) {
+	filter, _ := NewFilter(nil, nil, []string{"Slow*"}, nil)
+	filter = filter.Tests()
+
+	test := Block{Name: "Greets", Attributes: map[string]string{"test": "true"}}
+	slowTest := Block{Name: "Slow test", Attributes: map[string]string{"test": "true"}}
+	setup := Block{Name: "Setup", Attributes: map[string]string{}}
+
+	if !filter.Matches(1, test) {
+		t.Error("Expected a test block to match")
+	}
+	if filter.Matches(2, setup) {
+		t.Error("Expected a block without the test attribute not to match")
+	}
+	if filter.Matches(3, slowTest) {
+		t.Error("Expected skip patterns to apply to test blocks")
+	}
+}
@@ -0,0 +1,57 @@
+package rr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// alreadySatisfied checks the guards of a block, modelled on Ansible's creates and unless: the block
+// doesn't need to run when the path in its creates attribute exists, or when the command in its
+// unless attribute succeeds. It returns why the block is already satisfied, or "" if it has to run.
+//
+// The unless command runs like the block's commands would: in a shell session of its own that
+// interruptions reach, limited by the block's command timeout. Its output is discarded.
+func (r *Runner) alreadySatisfied(ctx context.Context, block Block, blockVars, runVars map[string]string) (string, error) {
+	variables := mergeVariables(r.opts.EnvVars, runVars, blockVars)
+	dir, err := blockDir(block, r.opts, variables)
+	if err != nil {
+		return "", err
+	}
+
+	if path, exists := block.Attributes["creates"]; exists {
+		path = substituteVariables(path, variables)
+		if _, err := os.Stat(resolvePath(dir, path)); err == nil {
+			return path + " exists", nil
+		}
+	}
+
+	if command, exists := block.Attributes["unless"]; exists {
+		command = substituteVariables(command, variables)
+		session, err := startShellSession("sh", dir, nil, nil, nil, commandEnvironment(block, r.opts, variables))
+		if err != nil {
+			return "", fmt.Errorf("error starting shell: %v", err)
+		}
+		defer session.close()
+		defer r.trackSession(session)()
+
+		guardCtx, cancel := withTimeout(ctx, durationAttribute(block, "command-timeout", r.opts.CommandTimeout))
+		defer cancel()
+		interruptedBefore := r.interruption() != nil
+		err = session.run(guardCtx, command)
+		r.noticeTerminalInterrupt(session)
+		var statusErr *exitStatusError
+		switch {
+		case !interruptedBefore && r.interruption() != nil:
+			// Interrupted while checking; the block is stopped rather than run
+			return "", &InterruptedError{Signal: r.interruption()}
+		case err == nil:
+			return command + " succeeded", nil
+		case !errors.As(err, &statusErr):
+			return "", &CommandError{Command: command, ExitCode: exitStatus(err), Err: err}
+		}
+	}
+
+	return "", nil
+}
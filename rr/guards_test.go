@@ -0,0 +1,99 @@
+package rr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRun_CreatesGuard(t *testing.T) {
+	tempDir := t.TempDir()
+	output := filepath.Join(tempDir, "build", "app")
+	runs := filepath.Join(tempDir, "runs")
+	doc := Parse("<!-- RR[Build]{creates=#out}\necho ran >> " + runs + "\nmkdir -p " + filepath.Dir(output) + " && touch #out\n-->\n" +
+		"<!-- RR[Deploy]{needs=Build}\ntrue\n-->")
+	doc.Globals = map[string]string{"out": output}
+
+	for run := 1; run <= 2; run++ {
+		var stdout bytes.Buffer
+		runner := NewRunner(Options{Stdout: &stdout, Trust: true})
+		if err := runner.Run(context.Background(), doc); err != nil {
+			t.Fatalf("Run %d: expected success, got %v", run, err)
+		}
+		statuses := resultStatuses(runner)
+		if run == 2 {
+			if statuses[0] != StatusSatisfied || statuses[1] != StatusSucceeded {
+				t.Errorf("Expected the build to be satisfied and the deploy to run, got %v", statuses)
+			}
+			if !strings.Contains(stdout.String(), "Skipping block 1: already satisfied, "+output+" exists") {
+				t.Errorf("Expected the satisfied guard to be reported, got %q", stdout.String())
+			}
+			if !strings.Contains(stdout.String(), "1 already satisfied") {
+				t.Errorf("Expected the summary to count satisfied blocks, got %q", stdout.String())
+			}
+		}
+	}
+
+	if content, _ := os.ReadFile(runs); string(content) != "ran\n" {
+		t.Errorf("Expected the block to run only once, got %q", content)
+	}
+}
+
+func TestRun_UnlessGuard(t *testing.T) {
+	doc := Parse("<!-- RR[Installed]{unless=\"test x$RR_GUARD = xyes\"}\nfalse\n-->\n" +
+		"<!-- RR[Missing]{unless=false}\necho installing\n-->")
+
+	var stdout bytes.Buffer
+	runner := NewRunner(Options{Stdout: &stdout, Trust: true, EnvVars: map[string]string{"RR_GUARD": "yes"}, ExportVars: true})
+	if err := runner.Run(context.Background(), doc); err != nil {
+		t.Fatalf("Expected the failing block to be skipped by its guard, got %v", err)
+	}
+	if statuses := resultStatuses(runner); statuses[0] != StatusSatisfied || statuses[1] != StatusSucceeded {
+		t.Errorf("Expected [satisfied succeeded], got %v", statuses)
+	}
+	if !strings.Contains(stdout.String(), "installing") {
+		t.Errorf("Expected the block whose guard failed to run, got %q", stdout.String())
+	}
+}
+
+func TestRun_UnlessGuardIsInterrupted(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "ran")
+	doc := Parse("<!-- RR[Slow Guard]{unless=\"sleep 5; false\"}\ntouch " + marker + "\n-->")
+
+	runner := NewRunner(Options{Stdout: &bytes.Buffer{}, Trust: true})
+	time.AfterFunc(200*time.Millisecond, func() { runner.Interrupt(os.Interrupt) })
+	start := time.Now()
+	err := runner.Run(context.Background(), doc)
+
+	var interrupted *InterruptedError
+	if !errors.As(err, &interrupted) {
+		t.Fatalf("Expected InterruptedError, got %v", err)
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Error("Expected the interrupt to reach the guard")
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("Expected the block not to run after its guard was interrupted")
+	}
+}
+
+func TestRun_UnlessGuardTimesOut(t *testing.T) {
+	doc := Parse("<!-- RR[Slow Guard]{unless=\"sleep 5\" command-timeout=200ms}\ntrue\n-->")
+
+	runner := NewRunner(Options{Stdout: &bytes.Buffer{}, Trust: true})
+	start := time.Now()
+	err := runner.Run(context.Background(), doc)
+
+	var timeoutErr *CommandError
+	if !errors.As(err, &timeoutErr) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the guard to time out, got %v", err)
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Error("Expected the command timeout to stop the guard")
+	}
+}
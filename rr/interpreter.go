@@ -0,0 +1,145 @@
+package rr
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// shellLanguages maps languages that are POSIX shells to the shell that runs them. Blocks in these
+// languages run command by command in one shell session.
+var shellLanguages = map[string]string{
+	"sh":    "sh",
+	"shell": "sh",
+	"bash":  "bash",
+	"zsh":   "zsh",
+	"ksh":   "ksh",
+	"dash":  "dash",
+}
+
+// scriptLanguages maps other well known languages to their interpreter. Blocks in these languages
+// run as a single script.
+var scriptLanguages = map[string]string{
+	"python":     "python3",
+	"python3":    "python3",
+	"py":         "python3",
+	"node":       "node",
+	"javascript": "node",
+	"js":         "node",
+	"ruby":       "ruby",
+	"rb":         "ruby",
+	"perl":       "perl",
+}
+
+// blockInterpreter returns the program that runs a block, and whether it runs the whole block as
+// one script rather than command by command in a shell session.
+//
+// The shell attribute names a POSIX shell. The lang attribute names a language, and an unknown
+// language is taken to be the name of its interpreter. Otherwise the language of a fenced block
+// is used if it is known, and sh if it isn't.
+func blockInterpreter(block Block) (string, bool) {
+	if shell, exists := block.Attributes["shell"]; exists {
+		return shell, false
+	}
+	if lang, exists := block.Attributes["lang"]; exists {
+		if interpreter, isScript := languageInterpreter(lang); interpreter != "" {
+			return interpreter, isScript
+		}
+		return lang, true
+	}
+	if interpreter, isScript := languageInterpreter(block.Language); interpreter != "" {
+		return interpreter, isScript
+	}
+	return "sh", false
+}
+
+// describeInterpreter describes the interpreter of a block for the output, e.g. "python3, runs as a
+// script", returning "" for blocks that run in sh
+func describeInterpreter(block Block) string {
+	interpreter, isScript := blockInterpreter(block)
+	switch {
+	case isScript:
+		return interpreter + ", runs as a script"
+	case interpreter != "sh":
+		return interpreter
+	}
+	return ""
+}
+
+// languageInterpreter looks up the interpreter of a known language, returning "" for unknown languages
+func languageInterpreter(lang string) (string, bool) {
+	lang = strings.ToLower(lang)
+	if shell, isShell := shellLanguages[lang]; isShell {
+		return shell, false
+	}
+	if interpreter, isScript := scriptLanguages[lang]; isScript {
+		return interpreter, true
+	}
+	return "", false
+}
+
+// runsAsScript reports whether the whole block runs as one script, see blockInterpreter
+func runsAsScript(block Block) bool {
+	_, isScript := blockInterpreter(block)
+	return isScript
+}
+
+// checkInterpreter makes sure the interpreter of a block is installed
+func checkInterpreter(interpreter string) error {
+	if _, err := exec.LookPath(interpreter); err != nil {
+		return &InterpreterError{Interpreter: interpreter, Err: err}
+	}
+	return nil
+}
+
+// writeScript saves the script of a block to a temporary file and returns the shell command that
+// runs it with the interpreter, along with a function that removes the file again
+func writeScript(interpreter, script string) (string, func(), error) {
+	file, err := os.CreateTemp("", "rr-script-*")
+	if err != nil {
+		return "", nil, err
+	}
+	remove := func() { os.Remove(file.Name()) }
+
+	if _, err := file.WriteString(script + "\n"); err != nil {
+		file.Close()
+		remove()
+		return "", nil, err
+	}
+	if err := file.Close(); err != nil {
+		remove()
+		return "", nil, err
+	}
+	return shellQuote(interpreter) + " " + shellQuote(filepath.ToSlash(file.Name())), remove, nil
+}
+
+// dedent removes leading and trailing blank lines and the indentation all other lines have in common
+func dedent(lines []string) string {
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	indent := -1
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		width := len(line) - len(strings.TrimLeft(line, " \t"))
+		if indent < 0 || width < indent {
+			indent = width
+		}
+	}
+
+	result := make([]string, len(lines))
+	for i, line := range lines {
+		if len(line) >= indent && indent > 0 {
+			line = line[indent:]
+		}
+		result[i] = strings.TrimRight(line, " \t\r")
+	}
+	return strings.Join(result, "\n")
+}
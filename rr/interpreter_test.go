@@ -0,0 +1,98 @@
+package rr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestBlockInterpreter(t *testing.T) {
+	tests := []struct {
+		block       Block
+		interpreter string
+		isScript    bool
+	}{
+		{Block{}, "sh", false},
+		{Block{Language: "bash"}, "bash", false},
+		{Block{Language: "Python"}, "python3", true},
+		{Block{Language: "console"}, "sh", false},
+		{Block{Language: "python", Attributes: map[string]string{"shell": "bash"}}, "bash", false},
+		{Block{Attributes: map[string]string{"lang": "js"}}, "node", true},
+		{Block{Language: "bash", Attributes: map[string]string{"lang": "php"}}, "php", true},
+		{Block{Attributes: map[string]string{"lang": "zsh"}}, "zsh", false},
+	}
+
+	for _, tt := range tests {
+		interpreter, isScript := blockInterpreter(tt.block)
+		if interpreter != tt.interpreter || isScript != tt.isScript {
+			t.Errorf("blockInterpreter(%+v) = (%q, %v), expected (%q, %v)", tt.block, interpreter, isScript, tt.interpreter, tt.isScript)
+		}
+	}
+}
+
+func TestDedent(t *testing.T) {
+	lines := []string{"", "    def greet():", "", "        print('hi')  ", "    greet()", "  "}
+
+	expected := "def greet():\n\n    print('hi')\ngreet()"
+	if got := dedent(lines); got != expected {
+		t.Errorf("Expected %q, got %q", expected, got)
+	}
+}
+
+func TestHashBlock_FenceLanguage(t *testing.T) {
+	plain := Parse("```rr\nprint(1)\n```").Blocks[0]
+	sh := Parse("```sh rr\nprint(1)\n```").Blocks[0]
+	unknown := Parse("```console rr\nprint(1)\n```").Blocks[0]
+	bash := Parse("```bash rr\nprint(1)\n```").Blocks[0]
+	zsh := Parse("```zsh rr\nprint(1)\n```").Blocks[0]
+	python := Parse("```python rr\nprint(1)\n```").Blocks[0]
+
+	if HashBlock(plain) != HashBlock(sh) || HashBlock(plain) != HashBlock(unknown) {
+		t.Error("Expected fence languages that run in sh not to change the hash")
+	}
+	if HashBlock(plain) == HashBlock(bash) || HashBlock(bash) == HashBlock(zsh) {
+		t.Error("Expected a fence language that picks another shell to change the hash")
+	}
+	if HashBlock(plain) == HashBlock(python) {
+		t.Error("Expected a block that runs as a python script to hash differently")
+	}
+}
+
+func TestRun_ScriptBlock(t *testing.T) {
+	if _, err := exec.LookPath("python3"); err != nil {
+		t.Skip("python3 is not installed")
+	}
+	doc := Parse("```python rr name=Greet\nimport os\nname = \"#who\"\nfor i in range(2):\n    print(f\"hello {name} {i}\")\nprint(os.environ.get(\"RR_SCRIPT_TEST\"))\n```")
+	doc.Globals = map[string]string{"who": "world"}
+
+	var stdout bytes.Buffer
+	runner := NewRunner(Options{Stdout: &stdout, Trust: true, EnvVars: map[string]string{"RR_SCRIPT_TEST": "exported"}, ExportVars: true})
+	if err := runner.Run(context.Background(), doc); err != nil {
+		t.Fatalf("Expected the script to run, got %v", err)
+	}
+
+	output := stdout.String()
+	for _, expected := range []string{"Executing: python3 script", "hello world 0\nhello world 1\nexported\n"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected output to contain %q, got %q", expected, output)
+		}
+	}
+}
+
+func TestRun_MissingInterpreter(t *testing.T) {
+	doc := Parse("```rr lang=rr-missing-interpreter\nanything\n```")
+
+	runner := NewRunner(Options{Stdout: &bytes.Buffer{}, Trust: true})
+	err := runner.Run(context.Background(), doc)
+
+	var interpreterErr *InterpreterError
+	if !errors.As(err, &interpreterErr) || interpreterErr.Interpreter != "rr-missing-interpreter" {
+		t.Fatalf("Expected an InterpreterError, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "rr-missing-interpreter is needed to run this block") {
+		t.Errorf("Unexpected error message: %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package rr
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// syncMarker is printed by the shell to its output pipes after every command, see outputCopier
+var syncMarker = []byte("\x00rr-sync\x00")
+
+// syncMarkerScript prints syncMarker to the shell's stdout
+const syncMarkerScript = `printf '\000rr-sync\000'`
+
+// outputCopier copies what the shell writes to one of its output streams to a writer that isn't a file.
+//
+// Copying happens in the background, so a command's output could still be on its way when the shell
+// reports the command's status. The shell therefore prints syncMarker after each command, and waitSync
+// waits for the copier to reach it before the session moves on.
+type outputCopier struct {
+	fd     int // the shell's file descriptor the copier is attached to
+	pipe   *os.File
+	dst    io.Writer
+	synced chan struct{}
+	done   chan struct{}
+}
+
+// newOutputCopier starts copying the given file descriptor of the shell to dst. It returns the copier and the end of its pipe that is
+// handed to the shell, which the caller closes once the shell has started.
+func newOutputCopier(dst io.Writer, fd int) (*outputCopier, *os.File, error) {
+	reader, writer, err := os.Pipe()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c := &outputCopier{
+		fd:     fd,
+		pipe:   reader,
+		dst:    dst,
+		synced: make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	go c.copy()
+	return c, writer, nil
+}
+
+// copy forwards the pipe's contents to dst, leaving out sync markers and signalling each of them
+func (c *outputCopier) copy() {
+	defer close(c.done)
+
+	buf := make([]byte, 32*1024)
+	var pending []byte // may hold the start of a marker that was split between reads
+	for {
+		n, err := c.pipe.Read(buf)
+		data := append(pending, buf[:n]...)
+		pending = nil
+
+		for {
+			i := bytes.Index(data, syncMarker)
+			if i < 0 {
+				break
+			}
+			c.write(data[:i])
+			select {
+			case c.synced <- struct{}{}:
+			default: // nobody is waiting, e.g. after the command was cancelled
+			}
+			data = data[i+len(syncMarker):]
+		}
+
+		keep := partialMarker(data)
+		c.write(data[:len(data)-keep])
+		pending = append(pending, data[len(data)-keep:]...)
+
+		if err != nil {
+			c.write(pending)
+			return
+		}
+	}
+}
+
+// write forwards output to dst. Nothing is written for empty output, so dst is left alone
+// once a sync marker has been signalled.
+func (c *outputCopier) write(p []byte) {
+	if len(p) > 0 {
+		c.dst.Write(p)
+	}
+}
+
+// waitSync waits until the copier has passed the next sync marker, or has stopped
+func (c *outputCopier) waitSync() {
+	select {
+	case <-c.synced:
+	case <-c.done:
+	}
+}
+
+// close stops the copier. Processes left behind by the shell may keep the pipe open,
+// so their output is only waited for up to a second.
+func (c *outputCopier) close() {
+	select {
+	case <-c.done:
+	case <-time.After(time.Second):
+	}
+	c.pipe.Close()
+	<-c.done
+}
+
+// partialMarker returns the length of the longest suffix of data that is a prefix of syncMarker
+func partialMarker(data []byte) int {
+	for n := min(len(data), len(syncMarker)-1); n > 0; n-- {
+		if bytes.Equal(syncMarker[:n], data[len(data)-n:]) {
+			return n
+		}
+	}
+	return 0
+}
+
+// lockedWriter serializes writes to a writer that is shared by the runner and the shell's output copiers
+type lockedWriter struct {
+	mu *sync.Mutex
+	w  io.Writer
+}
+
+func (l lockedWriter) Write(p []byte) (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.w.Write(p)
+}
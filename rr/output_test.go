@@ -0,0 +1,70 @@
+package rr
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestOutputCopier_SplitMarker(t *testing.T) {
+	var output bytes.Buffer
+	copier, childEnd, err := newOutputCopier(&output, 1)
+	if err != nil {
+		t.Fatalf("Failed to start copier: %v", err)
+	}
+
+	// Split the marker between two writes so it arrives in separate reads
+	childEnd.Write(append([]byte("first\n"), syncMarker[:4]...))
+	time.Sleep(50 * time.Millisecond)
+	childEnd.Write(append(append([]byte{}, syncMarker[4:]...), "second\x00\n"...))
+
+	copier.waitSync()
+	childEnd.Close()
+	copier.close()
+
+	if output.String() != "first\nsecond\x00\n" {
+		t.Errorf("Expected the marker to be removed from the output, got %q", output.String())
+	}
+}
+
+func TestPartialMarker(t *testing.T) {
+	tests := []struct {
+		data     string
+		expected int
+	}{
+		{"output", 0},
+		{"output\x00", 1},
+		{"output\x00rr-", 4},
+		{"output\x00rr-sync", 8},
+		{"\x00rr", 3},
+	}
+
+	for _, tt := range tests {
+		if n := partialMarker([]byte(tt.data)); n != tt.expected {
+			t.Errorf("partialMarker(%q) = %d, expected %d", tt.data, n, tt.expected)
+		}
+	}
+}
+
+func TestShellSession_OutputIsCopiedBeforeCommandReturns(t *testing.T) {
+	var output bytes.Buffer
+	writer := lockedWriter{mu: new(sync.Mutex), w: &output}
+	session, err := startShellSession("sh", "", strings.NewReader(""), writer, writer, nil)
+	if err != nil {
+		t.Fatalf("Failed to start shell session: %v", err)
+	}
+	defer session.close()
+
+	for i := 0; i < 20; i++ {
+		output.Reset()
+		if err := session.run(context.Background(), "echo out; echo err >&2"); err != nil {
+			t.Fatalf("Command failed: %v", err)
+		}
+		if output.String() != "out\nerr\n" && output.String() != "err\nout\n" {
+			t.Fatalf("Expected the command's output once it returned, got %q", output.String())
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package rr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// blockJob is a block of a batch that is ready to run
+type blockJob struct {
+	result    int // index of the block's result in its batch
+	index     int // 0-based block index in the document
+	block     Block
+	blockVars map[string]string // block variables with answered prompts
+
+	err       error         // set when the block failed
+	cancelled bool          // set when the block was stopped because another block of its batch failed
+	duration  time.Duration // how long the block ran
+}
+
+// label names the job's block in its output
+func (job *blockJob) label() string {
+	if job.block.Name != "" {
+		return job.block.Name
+	}
+	return "block " + strconv.Itoa(job.index+1)
+}
+
+// batches splits the planned blocks into batches that run one after the other. Consecutive blocks
+// of the same group form a batch whose blocks run side by side, unless parallel is below 2 or a
+// block needs another block of the batch.
+func batches(blocks []Block, order []int, parallel int) [][]int {
+	var result [][]int
+	for _, i := range order {
+		group := blocks[i].Attributes["group"]
+		if parallel > 1 && group != "" && len(result) > 0 {
+			last := result[len(result)-1]
+			if blocks[last[0]].Attributes["group"] == group && !needsAnyOf(blocks[i], blocks, last) {
+				result[len(result)-1] = append(last, i)
+				continue
+			}
+		}
+		result = append(result, []int{i})
+	}
+	return result
+}
+
+// needsAnyOf reports whether block needs one of the given blocks
+func needsAnyOf(block Block, blocks []Block, indexes []int) bool {
+	for _, name := range block.Needs {
+		for _, i := range indexes {
+			if blocks[i].Name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// runJobs runs the jobs of a batch and records their outcome in them. A single job runs with
+// the runner's streams; several jobs run side by side with up to Parallel of them at a time,
+// without input and with their output labelled line by line.
+func (r *Runner) runJobs(ctx context.Context, jobs []blockJob, runVars map[string]string) {
+	if len(jobs) == 1 {
+		job := &jobs[0]
+		if job.err == nil {
+			stdout, stderr := r.logOutput(job, r.stdout, r.stderr)
+			start := time.Now()
+			job.err = r.runBlock(ctx, job.block, job.blockVars, runVars, blockIO{stdin: r.commandInput(stdout), stdout: stdout, stderr: stderr, index: job.index + 1, label: job.label()})
+			job.duration = time.Since(start)
+		}
+		return
+	}
+
+	// A failure that aborts the run stops the other blocks of the batch
+	batchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var outMu sync.Mutex
+	slots := make(chan struct{}, r.opts.Parallel)
+	jobVars := make([]map[string]string, len(jobs))
+	var wg sync.WaitGroup
+	for k := range jobs {
+		job := &jobs[k]
+		if job.err != nil {
+			continue
+		}
+		// Captures are merged back once the batch is done, so blocks of a batch don't see each other's
+		jobVars[k] = mergeVariables(runVars)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-batchCtx.Done():
+			}
+			if batchCtx.Err() != nil {
+				job.err = batchCtx.Err()
+				job.cancelled = ctx.Err() == nil
+				return
+			}
+
+			label := job.label()
+			stdout := newPrefixWriter(r.stdout, &outMu, "["+label+"] ")
+			stderr := newPrefixWriter(r.stderr, &outMu, "["+label+"] ")
+			logOut, logErr := r.logOutput(job, stdout, stderr)
+			start := time.Now()
+			job.err = r.runBlock(batchCtx, job.block, job.blockVars, jobVars[k], blockIO{stdout: logOut, stderr: logErr, index: job.index + 1, label: label, labelled: true})
+			job.duration = time.Since(start)
+			stdout.Flush()
+			stderr.Flush()
+
+			switch {
+			case job.err == nil:
+			case ctx.Err() == nil && batchCtx.Err() != nil && errors.Is(job.err, context.Canceled):
+				job.cancelled = true
+				fmt.Fprintln(stdout, "Cancelled because another block failed")
+				stdout.Flush()
+			case failurePolicy(job.block, r.opts.KeepGoing) == OnErrorAbort:
+				cancel()
+			}
+		}()
+	}
+	wg.Wait()
+
+	for _, vars := range jobVars {
+		for k, v := range vars {
+			runVars[k] = v
+		}
+	}
+}
+
+// logOutput makes the output of a job also go to its block's log, when the run is logged
+func (r *Runner) logOutput(job *blockJob, stdout, stderr io.Writer) (io.Writer, io.Writer) {
+	if r.runLog == nil {
+		return stdout, stderr
+	}
+	log, err := r.runLog.blockLog(job.index+1, job.block.Name)
+	if err != nil {
+		fmt.Fprintf(stdout, "\nWarning: could not create the log of the block: %v\n", err)
+		return stdout, stderr
+	}
+	return io.MultiWriter(stdout, log), io.MultiWriter(stderr, log)
+}
+
+// prefixWriter writes complete lines to w, each non-blank one starting with a prefix. Partial lines are held
+// back until they are complete or the writer is flushed, so lines of different writers sharing
+// the same lock never interleave.
+type prefixWriter struct {
+	w      io.Writer
+	mu     *sync.Mutex
+	prefix []byte
+	buf    []byte
+}
+
+func newPrefixWriter(w io.Writer, mu *sync.Mutex, prefix string) *prefixWriter {
+	return &prefixWriter{w: w, mu: mu, prefix: []byte(prefix)}
+}
+
+func (p *prefixWriter) Write(data []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.buf = append(p.buf, data...)
+	end := bytes.LastIndexByte(p.buf, '\n')
+	if end < 0 {
+		return len(data), nil
+	}
+
+	var lines []byte
+	for _, line := range bytes.SplitAfter(p.buf[:end+1], []byte("\n")) {
+		// Blank lines keep separating the output without a label
+		if len(line) > 1 {
+			lines = append(lines, p.prefix...)
+		}
+		lines = append(lines, line...)
+	}
+	p.buf = append(p.buf[:0], p.buf[end+1:]...)
+
+	if _, err := p.w.Write(lines); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+// Flush writes a held back partial line, ending it with a newline
+func (p *prefixWriter) Flush() {
+	p.mu.Lock()
+	pending := len(p.buf) > 0
+	p.mu.Unlock()
+	if pending {
+		p.Write([]byte("\n"))
+	}
+}
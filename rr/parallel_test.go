@@ -0,0 +1,101 @@
+package rr
+
+import (
+	"bytes"
+	"context"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBatches(t *testing.T) {
+	doc := Parse("<!-- RR[Setup]\ntrue\n-->\n" +
+		"<!-- RR[Lint]{group=checks}\ntrue\n-->\n" +
+		"<!-- RR[Test]{group=checks}\ntrue\n-->\n" +
+		"<!-- RR[Report]{group=checks needs=Test}\ntrue\n-->\n" +
+		"<!-- RR[Deploy]\ntrue\n-->")
+	order := []int{0, 1, 2, 3, 4}
+
+	if got := batches(doc.Blocks, order, 4); !reflect.DeepEqual(got, [][]int{{0}, {1, 2}, {3}, {4}}) {
+		t.Errorf("Expected the group to be split where a block needs another of the group, got %v", got)
+	}
+	if got := batches(doc.Blocks, order, 1); len(got) != 5 {
+		t.Errorf("Expected every block on its own without parallelism, got %v", got)
+	}
+}
+
+func TestPrefixWriter_LabelsCompleteLines(t *testing.T) {
+	var out bytes.Buffer
+	var mu sync.Mutex
+	left := newPrefixWriter(&out, &mu, "[left] ")
+	right := newPrefixWriter(&out, &mu, "[right] ")
+
+	left.Write([]byte("one\ntw"))
+	right.Write([]byte("other\n"))
+	left.Write([]byte("o\nthree"))
+	left.Flush()
+
+	expected := "[left] one\n[right] other\n[left] two\n[left] three\n"
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q", expected, out.String())
+	}
+}
+
+func TestRun_ParallelGroup(t *testing.T) {
+	doc := Parse("<!-- RR[First]{group=slow}\nsleep 0.5\necho first done\n-->\n" +
+		"<!-- RR[Second]{group=slow}\nsleep 0.5\necho second done\n-->\n" +
+		"<!-- RR[After]\necho after\n-->")
+
+	var stdout bytes.Buffer
+	runner := NewRunner(Options{Stdout: &stdout, Trust: true, Parallel: 2})
+	start := time.Now()
+	if err := runner.Run(context.Background(), doc); err != nil {
+		t.Fatalf("Expected run to succeed, got %v", err)
+	}
+
+	if time.Since(start) > 900*time.Millisecond {
+		t.Errorf("Expected the blocks of the group to run at the same time, took %s", time.Since(start))
+	}
+	output := stdout.String()
+	for _, expected := range []string{"[First] first done\n", "[Second] second done\n", "\nafter\n"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected output to contain %q, got %q", expected, output)
+		}
+	}
+}
+
+func TestRun_ParallelFailureCancelsGroup(t *testing.T) {
+	doc := Parse("<!-- RR[Breaks]{group=checks}\nfalse\n-->\n" +
+		"<!-- RR[Slow]{group=checks}\nsleep 5\n-->\n" +
+		"<!-- RR[After]\ntrue\n-->")
+
+	var stdout bytes.Buffer
+	runner := NewRunner(Options{Stdout: &stdout, Trust: true, Parallel: 2})
+	start := time.Now()
+	runner.Run(context.Background(), doc)
+
+	if time.Since(start) > 3*time.Second {
+		t.Error("Expected the failure to stop the rest of the group")
+	}
+	statuses := resultStatuses(runner)
+	if !reflect.DeepEqual(statuses, []BlockStatus{StatusFailed, StatusCancelled, StatusSkipped}) {
+		t.Errorf("Expected [failed cancelled skipped], got %v", statuses)
+	}
+	if !strings.Contains(stdout.String(), "3 block(s): 0 succeeded, 1 failed, 1 skipped, 1 cancelled") {
+		t.Errorf("Expected cancelled blocks in the summary, got %q", stdout.String())
+	}
+}
+
+func TestRun_ParallelFailureWithContinue(t *testing.T) {
+	doc := Parse("<!-- RR[Breaks]{group=checks on-error=continue}\nfalse\n-->\n" +
+		"<!-- RR[Other]{group=checks}\nsleep 0.2\n-->")
+
+	runner := NewRunner(Options{Stdout: &bytes.Buffer{}, Trust: true, Parallel: 2})
+	runner.Run(context.Background(), doc)
+
+	if statuses := resultStatuses(runner); !reflect.DeepEqual(statuses, []BlockStatus{StatusFailed, StatusSucceeded}) {
+		t.Errorf("Expected a failure that doesn't abort to leave the group running, got %v", statuses)
+	}
+}
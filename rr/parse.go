@@ -0,0 +1,387 @@
+package rr
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Parse extracts all RR blocks and global variables from the readme content.
+// Blocks are either HTML comments starting with RR or code fences marked with rr in their info string.
+// Global variables are declared in <!-- RR-VARS --> comments.
+func Parse(content string) Document {
+	doc := Document{Globals: make(map[string]string)}
+
+	// Regex to match HTML comments that start with RR
+	// Matches: <!-- RR -->, <!-- RR[BlockName] --> or <!-- RR[BlockName]{key=value} -->
+	rrBlockRegex := regexp.MustCompile(`<!--\s*RR(\[([^\]]+)\])?(\{([^}]*)\})?\s*`)
+	rrVarsRegex := regexp.MustCompile(`<!--\s*RR-VARS\b`)
+
+	lines := strings.Split(content, "\n")
+	inBlock := false
+	inGlobals := false
+	var currentBlock *Block
+	var blockLines []string
+
+	// Fence marker (e.g. ``` or ~~~~) of the code fence we're currently in, if any
+	openFence := ""
+	inRunnableFence := false
+
+	// An output fence right after a block holds the output the block is expected to print
+	expectFor := -1 // index of the block an output fence would belong to
+	inOutputFence := false
+	var outputLines []string
+
+	// finishBlock processes the collected lines of the current block and stores the result
+	finishBlock := func() {
+		processBlockContent(currentBlock, blockLines)
+		if inGlobals {
+			// Only variables and prompts are taken from an RR-VARS comment
+			for k, v := range currentBlock.Variables {
+				doc.Globals[k] = v
+			}
+		} else {
+			doc.Blocks = append(doc.Blocks, *currentBlock)
+			if !currentBlock.HasExpectedOutput {
+				expectFor = len(doc.Blocks) - 1
+			}
+		}
+		inGlobals = false
+		currentBlock = nil
+		blockLines = nil
+	}
+
+	for lineNum, line := range lines {
+		// Inside a runnable code fence everything up to the closing fence is block content
+		if inRunnableFence {
+			if isFenceClose(line, openFence) {
+				finishBlock()
+				inRunnableFence = false
+				openFence = ""
+				continue
+			}
+			blockLines = append(blockLines, line)
+			continue
+		}
+
+		// Check if this line starts an RR block or an RR-VARS declaration
+		if rrVarsRegex.MatchString(line) || rrBlockRegex.MatchString(line) {
+			if inBlock {
+				// Close previous block if we encounter a new one
+				if currentBlock != nil {
+					finishBlock()
+				}
+			}
+
+			if rrVarsRegex.MatchString(line) {
+				currentBlock = newBlock("", lineNum+1, map[string]string{})
+				inGlobals = true
+			} else {
+				// Extract block name
+				matches := rrBlockRegex.FindStringSubmatch(line)
+				blockName := ""
+				if len(matches) > 2 && matches[2] != "" {
+					blockName = matches[2]
+				}
+
+				currentBlock = newBlock(blockName, lineNum+1, parseBlockAttributes(matches[4]))
+			}
+			blockLines = []string{}
+			inBlock = true
+			continue
+		}
+
+		// Check if this line ends the block
+		if inBlock && strings.Contains(line, "-->") {
+			// Remove the closing --> from the last line
+			lastLine := strings.TrimSuffix(strings.TrimSpace(line), "-->")
+			if lastLine != "" {
+				blockLines = append(blockLines, lastLine)
+			}
+
+			finishBlock()
+			inBlock = false
+			continue
+		}
+		if inBlock {
+			blockLines = append(blockLines, line)
+			continue
+		}
+
+		// Track code fences so that only fences marked with rr are run, and so that
+		// an rr fence shown as an example inside another fence is left alone
+		if inOutputFence {
+			if isFenceClose(line, openFence) {
+				doc.Blocks[expectFor].ExpectedOutput = dedent(outputLines)
+				doc.Blocks[expectFor].HasExpectedOutput = true
+				inOutputFence = false
+				openFence = ""
+				expectFor = -1
+				continue
+			}
+			outputLines = append(outputLines, line)
+			continue
+		}
+		if openFence != "" {
+			if isFenceClose(line, openFence) {
+				openFence = ""
+			}
+			continue
+		}
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		if marker, info, isFence := parseFenceOpen(line); isFence {
+			openFence = marker
+			if fields := strings.Fields(info); len(fields) == 1 && fields[0] == "output" && expectFor >= 0 {
+				inOutputFence = true
+				outputLines = nil
+				continue
+			}
+			if name, language, attributes, runnable := parseFenceInfo(info); runnable {
+				currentBlock = newBlock(name, lineNum+1, attributes)
+				currentBlock.Language = language
+				blockLines = []string{}
+				inRunnableFence = true
+			}
+		}
+		// Any other line is outside any RR block and is ignored, and ends the search for an output fence
+		expectFor = -1
+	}
+
+	// Handle case where block doesn't close properly
+	if (inBlock || inRunnableFence) && currentBlock != nil {
+		finishBlock()
+	}
+
+	return doc
+}
+
+// parseFenceOpen checks whether a line opens a code fence (``` or ~~~) and returns its marker and info string
+func parseFenceOpen(line string) (string, string, bool) {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 || len(trimmed) < 3 {
+		return "", "", false
+	}
+
+	fenceChar := trimmed[0]
+	if fenceChar != '`' && fenceChar != '~' {
+		return "", "", false
+	}
+
+	length := 0
+	for length < len(trimmed) && trimmed[length] == fenceChar {
+		length++
+	}
+	if length < 3 {
+		return "", "", false
+	}
+
+	info := strings.TrimSpace(trimmed[length:])
+	// Backtick fences can't have backticks in their info string
+	if fenceChar == '`' && strings.Contains(info, "`") {
+		return "", "", false
+	}
+
+	return trimmed[:length], info, true
+}
+
+// isFenceClose checks whether a line closes the code fence opened with the given marker
+func isFenceClose(line string, marker string) bool {
+	trimmed := strings.TrimSpace(line)
+	if len(line)-len(strings.TrimLeft(line, " ")) > 3 || !strings.HasPrefix(trimmed, marker) {
+		return false
+	}
+	// The closing fence may be longer than the opening one but can't have an info string
+	return strings.Trim(trimmed, marker[:1]) == ""
+}
+
+// parseFenceInfo checks whether a code fence info string marks the fence as runnable, e.g. bash rr name="Install".
+// It returns the block name, the fence's language and the remaining attributes of a runnable fence.
+func parseFenceInfo(info string) (string, string, map[string]string, bool) {
+	fields := strings.Fields(info)
+	if len(fields) == 0 {
+		return "", "", nil, false
+	}
+
+	// The rr marker comes first or right after the language
+	var language, attrs string
+	switch {
+	case fields[0] == "rr":
+		attrs = strings.TrimSpace(strings.TrimPrefix(info, "rr"))
+	case len(fields) > 1 && fields[1] == "rr":
+		language = fields[0]
+		attrs = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(strings.TrimPrefix(info, fields[0])), "rr"))
+	default:
+		return "", "", nil, false
+	}
+
+	attributes := parseBlockAttributes(attrs)
+	name := attributes["name"]
+	delete(attributes, "name")
+
+	return name, language, attributes, true
+}
+
+// parseBlockAttributes parses the attribute list of a block header, e.g. {tags=db,slow cwd="my dir" always}.
+// Attributes are separated by whitespace, values may be double quoted and bare attributes are set to "true".
+func parseBlockAttributes(attrs string) map[string]string {
+	attributes := make(map[string]string)
+
+	i := 0
+	for i < len(attrs) {
+		// Skip whitespace between attributes
+		if attrs[i] == ' ' || attrs[i] == '\t' {
+			i++
+			continue
+		}
+
+		// Read the key up to '=' or the next whitespace
+		start := i
+		for i < len(attrs) && attrs[i] != '=' && attrs[i] != ' ' && attrs[i] != '\t' {
+			i++
+		}
+		key := attrs[start:i]
+
+		if i >= len(attrs) || attrs[i] != '=' {
+			attributes[key] = "true"
+			continue
+		}
+		i++ // skip '='
+
+		var value string
+		if i < len(attrs) && attrs[i] == '"' {
+			i++
+			start = i
+			for i < len(attrs) && attrs[i] != '"' {
+				i++
+			}
+			value = attrs[start:i]
+			i++ // skip closing quote
+		} else {
+			start = i
+			for i < len(attrs) && attrs[i] != ' ' && attrs[i] != '\t' {
+				i++
+			}
+			value = attrs[start:i]
+		}
+
+		attributes[key] = value
+	}
+
+	return attributes
+}
+
+// splitList splits a comma separated attribute value into its trimmed, non-empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// processBlockContent processes the content of an RR block to extract variables, prompts, and commands.
+// A block that runs as a script, e.g. a python block, has its whole content as its only command.
+// Lines after an expect: line are the output the block is expected to print.
+func processBlockContent(block *Block, lines []string) {
+	lines, expected, hasExpected := splitExpect(lines)
+	if hasExpected {
+		block.ExpectedOutput, block.HasExpectedOutput = expected, true
+	}
+
+	if runsAsScript(*block) {
+		if script := dedent(lines); script != "" {
+			block.Commands = []string{script}
+		}
+		return
+	}
+
+	var currentCommand strings.Builder
+	var commands []string
+
+	varAssignRegex := regexp.MustCompile(`^\s*([a-zA-Z0-9_-]+)\s*=\s*"([^"]+)"\s*$`)
+	promptRegex := regexp.MustCompile(`^\s*([a-zA-Z0-9_-]+)\s*=\s*#prompt\("([^"]+)"\)\s*$`)
+
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		// Check for variable assignment
+		if matches := varAssignRegex.FindStringSubmatch(line); matches != nil {
+			// Save any pending command before processing variable
+			if currentCommand.Len() > 0 {
+				cmd := strings.TrimSpace(currentCommand.String())
+				if cmd != "" {
+					commands = append(commands, cmd)
+				}
+				currentCommand.Reset()
+			}
+			block.Variables[matches[1]] = matches[2]
+			continue
+		}
+
+		// Check for prompt assignment
+		if matches := promptRegex.FindStringSubmatch(line); matches != nil {
+			// Save any pending command before processing prompt
+			if currentCommand.Len() > 0 {
+				cmd := strings.TrimSpace(currentCommand.String())
+				if cmd != "" {
+					commands = append(commands, cmd)
+				}
+				currentCommand.Reset()
+			}
+			// Prompt will be handled during execution
+			block.Variables[matches[1]] = "#PROMPT:" + matches[2]
+			continue
+		}
+
+		// This is a command line
+		if currentCommand.Len() > 0 {
+			currentCommand.WriteString(" ")
+		}
+
+		// Handle multi-line commands (backslash continuation)
+		trimmedLine := strings.TrimRight(line, " \t")
+		if strings.HasSuffix(trimmedLine, "\\") {
+			currentCommand.WriteString(strings.TrimSuffix(trimmedLine, "\\"))
+			continue //next line
+		}
+
+		// Add the line to current command
+		currentCommand.WriteString(line)
+
+		// If no backslash, this command is complete
+		cmd := strings.TrimSpace(currentCommand.String())
+		if cmd != "" {
+			commands = append(commands, cmd)
+		}
+		currentCommand.Reset()
+	}
+
+	// Add any remaining command
+	if currentCommand.Len() > 0 {
+		cmd := strings.TrimSpace(currentCommand.String())
+		if cmd != "" {
+			commands = append(commands, cmd)
+		}
+	}
+
+	block.Commands = commands
+}
+
+// ParseCapture checks whether a block command is a capture, e.g. container-id = #capture(docker run -d app),
+// and returns the variable name and the command whose output is captured
+func ParseCapture(cmd string) (string, string, bool) {
+	captureRegex := regexp.MustCompile(`^\s*([a-zA-Z0-9_-]+)\s*=\s*#capture\((.*)\)\s*$`)
+
+	matches := captureRegex.FindStringSubmatch(cmd)
+	if matches == nil {
+		return "", "", false
+	}
+	return matches[1], strings.TrimSpace(matches[2]), true
+}
@@ -0,0 +1,716 @@
+package rr
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse_BasicBlock(t *testing.T) {
+	content := `<!-- RR
+echo "Hello World"
+-->`
+
+	blocks := Parse(content).Blocks
+	if len(blocks) != 1 {
+		t.Fatalf("Expected 1 block, got %d", len(blocks))
+	}
+
+	block := blocks[0]
+	if block.Name != "" {
+		t.Errorf("Expected empty name, got %s", block.Name)
+	}
+	if len(block.Commands) != 1 {
+		t.Fatalf("Expected 1 command, got %d", len(block.Commands))
+	}
+	if block.Commands[0] != `echo "Hello World"` {
+		t.Errorf("Expected command 'echo \"Hello World\"', got '%s'", block.Commands[0])
+	}
+}
+
+func TestParse_NamedBlock(t *testing.T) {
+	content := `<!-- RR[Test Block]
+echo "Test"
+-->`
+
+	blocks := Parse(content).Blocks
+	if len(blocks) != 1 {
+		t.Fatalf("Expected 1 block, got %d", len(blocks))
+	}
+
+	block := blocks[0]
+	if block.Name != "Test Block" {
+		t.Errorf("Expected name 'Test Block', got '%s'", block.Name)
+	}
+}
+
+func TestParse_MultipleBlocks(t *testing.T) {
+	content := `<!-- RR[First]
+echo "First"
+-->
+Some text in between
+<!-- RR[Second]
+echo "Second"
+-->`
+
+	blocks := Parse(content).Blocks
+	if len(blocks) != 2 {
+		t.Fatalf("Expected 2 blocks, got %d", len(blocks))
+	}
+
+	if blocks[0].Name != "First" {
+		t.Errorf("Expected first block name 'First', got '%s'", blocks[0].Name)
+	}
+	if blocks[1].Name != "Second" {
+		t.Errorf("Expected second block name 'Second', got '%s'", blocks[1].Name)
+	}
+}
+
+func TestParse_IgnoresOutsideBlocks(t *testing.T) {
+	content := `echo "This should be ignored"
+<!-- RR[Test]
+echo "This should be parsed"
+-->
+echo "This should also be ignored"`
+
+	blocks := Parse(content).Blocks
+	if len(blocks) != 1 {
+		t.Fatalf("Expected 1 block, got %d", len(blocks))
+	}
+
+	if len(blocks[0].Commands) != 1 {
+		t.Fatalf("Expected 1 command in block, got %d", len(blocks[0].Commands))
+	}
+	if !strings.Contains(blocks[0].Commands[0], "This should be parsed") {
+		t.Errorf("Block should contain 'This should be parsed', got '%s'", blocks[0].Commands[0])
+	}
+}
+
+func TestProcessBlockContent_Variables(t *testing.T) {
+	block := &Block{
+		Name:      "Test",
+		Variables: make(map[string]string),
+		Commands:  []string{},
+	}
+
+	lines := []string{
+		`my-var = "test value"`,
+		`echo #my-var`,
+	}
+
+	processBlockContent(block, lines)
+
+	if block.Variables["my-var"] != "test value" {
+		t.Errorf("Expected variable 'my-var' to be 'test value', got '%s'", block.Variables["my-var"])
+	}
+	if len(block.Commands) != 1 {
+		t.Fatalf("Expected 1 command, got %d", len(block.Commands))
+	}
+	if block.Commands[0] != "echo #my-var" {
+		t.Errorf("Expected command 'echo #my-var', got '%s'", block.Commands[0])
+	}
+}
+
+func TestProcessBlockContent_MultipleVariables(t *testing.T) {
+	block := &Block{
+		Name:      "Test",
+		Variables: make(map[string]string),
+		Commands:  []string{},
+	}
+
+	lines := []string{
+		`var1 = "value1"`,
+		`var2 = "value2"`,
+		`echo #var1 #var2`,
+	}
+
+	processBlockContent(block, lines)
+
+	if block.Variables["var1"] != "value1" {
+		t.Errorf("Expected var1 to be 'value1', got '%s'", block.Variables["var1"])
+	}
+	if block.Variables["var2"] != "value2" {
+		t.Errorf("Expected var2 to be 'value2', got '%s'", block.Variables["var2"])
+	}
+}
+
+func TestProcessBlockContent_Prompts(t *testing.T) {
+	block := &Block{
+		Name:      "Test",
+		Variables: make(map[string]string),
+		Commands:  []string{},
+	}
+
+	lines := []string{
+		`my-name = #prompt("What is your name?")`,
+		`echo "Hello #my-name"`,
+	}
+
+	processBlockContent(block, lines)
+
+	expectedPrompt := "#PROMPT:What is your name?"
+	if block.Variables["my-name"] != expectedPrompt {
+		t.Errorf("Expected prompt variable to be '%s', got '%s'", expectedPrompt, block.Variables["my-name"])
+	}
+}
+
+func TestProcessBlockContent_MultiLineCommands(t *testing.T) {
+	block := &Block{
+		Name:      "Test",
+		Variables: make(map[string]string),
+		Commands:  []string{},
+	}
+
+	lines := []string{
+		`echo "First" && \`,
+		`echo "Second" && \`,
+		`echo "Third"`,
+	}
+
+	processBlockContent(block, lines)
+
+	if len(block.Commands) != 1 {
+		t.Fatalf("Expected 1 command, got %d", len(block.Commands))
+	}
+
+	expected := `echo "First" &&  echo "Second" &&  echo "Third"`
+	if block.Commands[0] != expected {
+		t.Errorf("Expected multi-line command '%s', got '%s'", expected, block.Commands[0])
+	}
+}
+
+func TestProcessBlockContent_MultipleCommands(t *testing.T) {
+	block := &Block{
+		Name:      "Test",
+		Variables: make(map[string]string),
+		Commands:  []string{},
+	}
+
+	lines := []string{
+		`echo "First command"`,
+		`echo "Second command"`,
+		`echo "Third command"`,
+	}
+
+	processBlockContent(block, lines)
+
+	if len(block.Commands) != 3 {
+		t.Fatalf("Expected 3 commands, got %d", len(block.Commands))
+	}
+}
+
+func TestProcessBlockContent_VariablesAndCommands(t *testing.T) {
+	block := &Block{
+		Name:      "Test",
+		Variables: make(map[string]string),
+		Commands:  []string{},
+	}
+
+	lines := []string{
+		`my-var = "test"`,
+		`echo #my-var`,
+		`another-var = "value"`,
+		`echo #another-var`,
+	}
+
+	processBlockContent(block, lines)
+
+	if block.Variables["my-var"] != "test" {
+		t.Errorf("Expected my-var to be 'test'")
+	}
+	if block.Variables["another-var"] != "value" {
+		t.Errorf("Expected another-var to be 'value'")
+	}
+	if len(block.Commands) != 2 {
+		t.Fatalf("Expected 2 commands, got %d", len(block.Commands))
+	}
+}
+
+func TestParse_ComplexExample(t *testing.T) {
+	content := `# Project README
+
+Some documentation here.
+
+<!-- RR[Setup]
+    env = "development"
+    echo "Setting up #env environment"
+-->
+
+More documentation.
+
+<!-- RR[Deploy]
+    project-name = #prompt("Project name?")
+    echo "Deploying #project-name"
+    deploy.sh #project-name
+-->
+
+<!-- RR[Multi-line]
+    echo "Starting..." && \
+    sleep 1 && \
+    echo "Done"
+-->
+`
+
+	blocks := Parse(content).Blocks
+	if len(blocks) != 3 {
+		t.Fatalf("Expected 3 blocks, got %d", len(blocks))
+	}
+
+	// Check first block
+	if blocks[0].Name != "Setup" {
+		t.Errorf("Expected first block name 'Setup', got '%s'", blocks[0].Name)
+	}
+	if blocks[0].Variables["env"] != "development" {
+		t.Errorf("Expected env variable to be 'development'")
+	}
+
+	// Check second block
+	if blocks[1].Name != "Deploy" {
+		t.Errorf("Expected second block name 'Deploy', got '%s'", blocks[1].Name)
+	}
+	if !strings.HasPrefix(blocks[1].Variables["project-name"], "#PROMPT:") {
+		t.Error("Expected project-name to be a prompt variable")
+	}
+
+	// Check third block
+	if blocks[2].Name != "Multi-line" {
+		t.Errorf("Expected third block name 'Multi-line', got '%s'", blocks[2].Name)
+	}
+	if len(blocks[2].Commands) != 1 {
+		t.Fatalf("Expected 1 multi-line command, got %d", len(blocks[2].Commands))
+	}
+}
+
+func TestParse_UnclosedBlock(t *testing.T) {
+	content := `<!-- RR[Test]
+echo "Test"
+`
+
+	blocks := Parse(content).Blocks
+	if len(blocks) != 1 {
+		t.Fatalf("Expected 1 block even if unclosed, got %d", len(blocks))
+	}
+}
+
+func TestProcessBlockContent_EmptyLines(t *testing.T) {
+	block := &Block{
+		Name:      "Test",
+		Variables: make(map[string]string),
+		Commands:  []string{},
+	}
+
+	lines := []string{
+		"",
+		`echo "test"`,
+		"   ",
+		`echo "another"`,
+		"",
+	}
+
+	processBlockContent(block, lines)
+
+	if len(block.Commands) != 2 {
+		t.Fatalf("Expected 2 commands (empty lines should be ignored), got %d", len(block.Commands))
+	}
+}
+
+func TestParse_RealWorldExample(t *testing.T) {
+	content := `# My Project
+
+This is a project README with embedded commands.
+
+<!-- RR[Install Dependencies]
+npm install
+-->
+
+<!-- RR[Build]
+    env = "production"
+    npm run build --env #env
+-->
+
+<!-- RR[Deploy]
+    project = #prompt("Project name?")
+    deploy.sh --project #project
+-->
+
+Regular markdown content here.
+
+` + "```" + `bash
+echo "This code block should be ignored"
+` + "```" + `
+
+<!-- RR[Cleanup]
+rm -rf node_modules
+-->
+`
+
+	blocks := Parse(content).Blocks
+	if len(blocks) != 4 {
+		t.Fatalf("Expected 4 blocks, got %d", len(blocks))
+	}
+
+	// Verify blocks are parsed correctly
+	if blocks[0].Name != "Install Dependencies" {
+		t.Errorf("Expected first block name 'Install Dependencies'")
+	}
+
+	if blocks[1].Name != "Build" {
+		t.Errorf("Expected second block name 'Build'")
+	}
+	if blocks[1].Variables["env"] != "production" {
+		t.Error("Expected env variable in Build block")
+	}
+
+	if blocks[2].Name != "Deploy" {
+		t.Errorf("Expected third block name 'Deploy'")
+	}
+	if !strings.HasPrefix(blocks[2].Variables["project"], "#PROMPT:") {
+		t.Error("Expected project to be a prompt variable")
+	}
+
+	if blocks[3].Name != "Cleanup" {
+		t.Errorf("Expected fourth block name 'Cleanup'")
+	}
+}
+
+func TestProcessBlockContent_ComplexScenario(t *testing.T) {
+	block := &Block{
+		Name:      "Complex",
+		Variables: make(map[string]string),
+		Commands:  []string{},
+	}
+
+	lines := []string{
+		`var1 = "value1"`,
+		`var2 = #prompt("Enter value:")`,
+		`echo "First: #var1"`,
+		`echo "Second: #var2" && \`,
+		`echo "Third line"`,
+		`var3 = "value3"`,
+		`echo "Fourth: #var3"`,
+	}
+
+	processBlockContent(block, lines)
+
+	// Check variables
+	if block.Variables["var1"] != "value1" {
+		t.Error("Expected var1 to be set")
+	}
+	if !strings.HasPrefix(block.Variables["var2"], "#PROMPT:") {
+		t.Error("Expected var2 to be a prompt")
+	}
+	if block.Variables["var3"] != "value3" {
+		t.Error("Expected var3 to be set")
+	}
+
+	// Check commands
+	if len(block.Commands) != 3 {
+		t.Fatalf("Expected 3 commands, got %d", len(block.Commands))
+	}
+
+	// First command
+	if !strings.Contains(block.Commands[0], "First: #var1") {
+		t.Errorf("Expected first command to contain 'First: #var1', got '%s'", block.Commands[0])
+	}
+
+	// Second command (multi-line)
+	if !strings.Contains(block.Commands[1], "Second: #var2") {
+		t.Errorf("Expected second command to contain 'Second: #var2', got '%s'", block.Commands[1])
+	}
+	if !strings.Contains(block.Commands[1], "Third line") {
+		t.Errorf("Expected second command to contain 'Third line', got '%s'", block.Commands[1])
+	}
+
+	// Third command
+	if !strings.Contains(block.Commands[2], "Fourth: #var3") {
+		t.Errorf("Expected third command to contain 'Fourth: #var3', got '%s'", block.Commands[2])
+	}
+}
+
+func TestParse_IgnoresCodeBlocks(t *testing.T) {
+	content := `# README
+
+` + "```" + `bash
+echo "This should be ignored"
+` + "```" + `
+
+<!-- RR[Test]
+echo "This should be parsed"
+-->
+
+` + "```" + `bash
+rm -rf /  # This dangerous command should be ignored
+` + "```" + `
+`
+
+	blocks := Parse(content).Blocks
+	if len(blocks) != 1 {
+		t.Fatalf("Expected 1 block, got %d", len(blocks))
+	}
+
+	if blocks[0].Name != "Test" {
+		t.Errorf("Expected block name 'Test', got '%s'", blocks[0].Name)
+	}
+}
+
+func TestProcessBlockContent_CommandWithVariables(t *testing.T) {
+	block := &Block{
+		Name:      "Test",
+		Variables: make(map[string]string),
+		Commands:  []string{},
+	}
+
+	lines := []string{
+		`var = "test"`,
+		`echo "Value: #var"`,
+		`echo "Another: #var"`,
+	}
+
+	processBlockContent(block, lines)
+
+	if block.Variables["var"] != "test" {
+		t.Error("Expected var to be set")
+	}
+	if len(block.Commands) != 2 {
+		t.Fatalf("Expected 2 commands, got %d", len(block.Commands))
+	}
+}
+
+func TestParse_Attributes(t *testing.T) {
+	content := `<!-- RR[Seed DB]{tags=db,slow cwd="my dir" always}
+echo "Seeding"
+-->`
+
+	blocks := Parse(content).Blocks
+	if len(blocks) != 1 {
+		t.Fatalf("Expected 1 block, got %d", len(blocks))
+	}
+
+	block := blocks[0]
+	if block.Name != "Seed DB" {
+		t.Errorf("Expected name 'Seed DB', got '%s'", block.Name)
+	}
+	if len(block.Tags) != 2 || block.Tags[0] != "db" || block.Tags[1] != "slow" {
+		t.Errorf("Expected tags [db slow], got %v", block.Tags)
+	}
+	if block.Attributes["cwd"] != "my dir" {
+		t.Errorf("Expected cwd attribute 'my dir', got '%s'", block.Attributes["cwd"])
+	}
+	if block.Attributes["always"] != "true" {
+		t.Errorf("Expected bare attribute to be 'true', got '%s'", block.Attributes["always"])
+	}
+	if len(block.Commands) != 1 {
+		t.Errorf("Expected 1 command, got %d", len(block.Commands))
+	}
+}
+
+func TestParseBlockAttributes_Empty(t *testing.T) {
+	attributes := parseBlockAttributes("")
+	if len(attributes) != 0 {
+		t.Errorf("Expected no attributes, got %v", attributes)
+	}
+}
+
+func TestParse_FencedBlock(t *testing.T) {
+	content := "# Setup\n\n```bash rr name=\"Install\" tags=setup\nversion = \"1.2\"\nnpm install\necho #version\n```\n"
+
+	blocks := Parse(content).Blocks
+	if len(blocks) != 1 {
+		t.Fatalf("Expected 1 block, got %d", len(blocks))
+	}
+
+	block := blocks[0]
+	if block.Name != "Install" {
+		t.Errorf("Expected name 'Install', got '%s'", block.Name)
+	}
+	if block.Line != 3 {
+		t.Errorf("Expected block on line 3, got %d", block.Line)
+	}
+	if len(block.Tags) != 1 || block.Tags[0] != "setup" {
+		t.Errorf("Expected tags [setup], got %v", block.Tags)
+	}
+	if block.Variables["version"] != "1.2" {
+		t.Errorf("Expected variable 'version' to be '1.2', got '%s'", block.Variables["version"])
+	}
+	if len(block.Commands) != 2 || block.Commands[0] != "npm install" || block.Commands[1] != "echo #version" {
+		t.Errorf("Unexpected commands: %v", block.Commands)
+	}
+}
+
+func TestParse_FencedBlockWithoutLanguage(t *testing.T) {
+	content := "~~~ rr\necho \"tilde fence\"\n~~~"
+
+	blocks := Parse(content).Blocks
+	if len(blocks) != 1 {
+		t.Fatalf("Expected 1 block, got %d", len(blocks))
+	}
+	if blocks[0].Name != "" {
+		t.Errorf("Expected empty name, got '%s'", blocks[0].Name)
+	}
+	if len(blocks[0].Commands) != 1 {
+		t.Errorf("Expected 1 command, got %d", len(blocks[0].Commands))
+	}
+}
+
+func TestParse_FencedBlockMixedWithComments(t *testing.T) {
+	content := `<!-- RR[First]
+echo "comment"
+-->
+
+` + "```sh rr name=Second" + `
+echo "fence"
+` + "```" + `
+
+` + "```bash" + `
+echo "not runnable"
+` + "```" + `
+
+<!-- RR[Third]
+echo "comment again"
+-->`
+
+	blocks := Parse(content).Blocks
+	if len(blocks) != 3 {
+		t.Fatalf("Expected 3 blocks, got %d", len(blocks))
+	}
+
+	expected := []string{"First", "Second", "Third"}
+	for i, name := range expected {
+		if blocks[i].Name != name {
+			t.Errorf("Expected block %d to be named '%s', got '%s'", i+1, name, blocks[i].Name)
+		}
+	}
+}
+
+func TestParse_FencedBlockInsideOtherFence(t *testing.T) {
+	// Documentation showing the fenced syntax must not be run itself
+	content := "````markdown\n```bash rr\nrm -rf /tmp/example\n```\n````\n"
+
+	blocks := Parse(content).Blocks
+	if len(blocks) != 0 {
+		t.Fatalf("Expected 0 blocks, got %d", len(blocks))
+	}
+}
+
+func TestParse_FencedBlockHashMatchesComment(t *testing.T) {
+	comment := Parse("<!-- RR[Install]\nnpm install\n-->").Blocks
+	fenced := Parse("```sh rr name=Install\nnpm install\n```").Blocks
+
+	if len(comment) != 1 || len(fenced) != 1 {
+		t.Fatalf("Expected 1 block each, got %d and %d", len(comment), len(fenced))
+	}
+	if HashBlock(comment[0]) != HashBlock(fenced[0]) {
+		t.Error("Expected the same block written as a comment or a fence to hash the same")
+	}
+}
+
+func TestParseFenceInfo(t *testing.T) {
+	tests := []struct {
+		info     string
+		runnable bool
+		name     string
+		language string
+	}{
+		{"bash", false, "", ""},
+		{"", false, "", ""},
+		{"rr", true, "", ""},
+		{"bash rr", true, "", "bash"},
+		{`bash rr name="Install deps"`, true, "Install deps", "bash"},
+		{"bash rrr", false, "", ""},
+		{"python script rr", false, "", ""},
+		{"python rr", true, "", "python"},
+	}
+
+	for _, tt := range tests {
+		name, language, _, runnable := parseFenceInfo(tt.info)
+		if runnable != tt.runnable || name != tt.name || language != tt.language {
+			t.Errorf("parseFenceInfo(%q) = (%q, %q, %v), expected (%q, %q, %v)", tt.info, name, language, runnable, tt.name, tt.language, tt.runnable)
+		}
+	}
+}
+
+func TestParse_ScriptBlockKeepsItsContent(t *testing.T) {
+	content := "```python rr name=Greet\n    name = \"world\"\n    if name:\n        print(\"hello\", name)\n```\n"
+
+	blocks := Parse(content).Blocks
+	if len(blocks) != 1 {
+		t.Fatalf("Expected 1 block, got %d", len(blocks))
+	}
+	block := blocks[0]
+	if block.Language != "python" {
+		t.Errorf("Expected language 'python', got '%s'", block.Language)
+	}
+	if len(block.Variables) != 0 {
+		t.Errorf("Expected no variables in a python block, got %v", block.Variables)
+	}
+	expected := "name = \"world\"\nif name:\n    print(\"hello\", name)"
+	if len(block.Commands) != 1 || block.Commands[0] != expected {
+		t.Errorf("Expected the dedented script as the only command, got %q", block.Commands)
+	}
+}
+
+func TestParse_GlobalVariables(t *testing.T) {
+	content := `<!-- RR-VARS
+    api-url = "http://localhost:8080"
+    token = #prompt("API token?")
+-->
+
+<!-- RR[Call API]
+curl #api-url
+-->`
+
+	doc := Parse(content)
+	if len(doc.Blocks) != 1 {
+		t.Fatalf("Expected 1 block (RR-VARS is not a block), got %d", len(doc.Blocks))
+	}
+	if doc.Blocks[0].Name != "Call API" {
+		t.Errorf("Expected block name 'Call API', got '%s'", doc.Blocks[0].Name)
+	}
+	if doc.Globals["api-url"] != "http://localhost:8080" {
+		t.Errorf("Expected global 'api-url', got '%s'", doc.Globals["api-url"])
+	}
+	if doc.Globals["token"] != "#PROMPT:API token?" {
+		t.Errorf("Expected global prompt 'token', got '%s'", doc.Globals["token"])
+	}
+}
+
+func TestParseCapture(t *testing.T) {
+	varName, cmd, isCapture := ParseCapture("container-id = #capture(docker run -d $(echo img))")
+	if !isCapture {
+		t.Fatal("Expected command to be a capture")
+	}
+	if varName != "container-id" {
+		t.Errorf("Expected variable 'container-id', got '%s'", varName)
+	}
+	if cmd != "docker run -d $(echo img)" {
+		t.Errorf("Expected captured command 'docker run -d $(echo img)', got '%s'", cmd)
+	}
+
+	if _, _, isCapture := ParseCapture("echo #capture"); isCapture {
+		t.Error("Expected plain command not to be a capture")
+	}
+}
+
+func TestParse_LineNumbers(t *testing.T) {
+	content := `# Title
+
+<!-- RR[First]
+echo "First"
+-->
+
+Some text
+
+<!-- RR[Second]
+echo "Second"
+-->`
+
+	blocks := Parse(content).Blocks
+	if len(blocks) != 2 {
+		t.Fatalf("Expected 2 blocks, got %d", len(blocks))
+	}
+	if blocks[0].Line != 3 {
+		t.Errorf("Expected first block on line 3, got %d", blocks[0].Line)
+	}
+	if blocks[1].Line != 9 {
+		t.Errorf("Expected second block on line 9, got %d", blocks[1].Line)
+	}
+}
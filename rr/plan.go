@@ -0,0 +1,149 @@
+package rr
+
+// plan returns the 0-based indexes of the blocks that take part in a run, in the order they run.
+//
+// The blocks selected by the filter take part along with every block they need, unless a needed
+// block matches a skip pattern. Each block runs after the blocks it needs and otherwise in the
+// order the blocks appear in the readme. Cleanup blocks, see runsAlways, take part however the
+// blocks were selected, unless they match a skip pattern, and come after all other blocks.
+// Attribute values are checked for every block, however the blocks were selected.
+func plan(blocks []Block, filter Filter) ([]int, error) {
+	if err := validateAttributes(blocks); err != nil {
+		return nil, err
+	}
+	needs, err := resolveNeeds(blocks)
+	if err != nil {
+		return nil, err
+	}
+	for i, block := range blocks {
+		for _, need := range needs[i] {
+			if !runsAlways(block) && runsAlways(blocks[need]) {
+				return nil, &DependencyError{Index: i + 1, Name: block.Name, Need: blocks[need].Name,
+					Reason: "that is a cleanup block, which runs after all other blocks"}
+			}
+		}
+	}
+
+	selected := make([]bool, len(blocks))
+	var include func(i int)
+	include = func(i int) {
+		if selected[i] {
+			return
+		}
+		selected[i] = true
+		for _, need := range needs[i] {
+			if !filter.Skips(blocks[need]) {
+				include(need)
+			}
+		}
+	}
+	for i, block := range blocks {
+		if filter.Matches(i+1, block) {
+			include(i)
+		}
+	}
+	// The blocks a cleanup block needs only order it, so they aren't pulled in with it
+	for i, block := range blocks {
+		if runsAlways(block) && !filter.Skips(block) {
+			selected[i] = true
+		}
+	}
+
+	// Order every block, not just the selected ones, so a cycle is reported however the blocks were selected
+	order, err := dependencyOrder(blocks, needs)
+	if err != nil {
+		return nil, err
+	}
+
+	var planned, cleanup []int
+	for _, i := range order {
+		switch {
+		case !selected[i]:
+		case runsAlways(blocks[i]):
+			cleanup = append(cleanup, i)
+		default:
+			planned = append(planned, i)
+		}
+	}
+	return append(planned, cleanup...), nil
+}
+
+// runsAlways reports whether a block is a cleanup block, which runs at the end of the run even
+// when blocks before it failed, were skipped or the run was interrupted
+func runsAlways(block Block) bool {
+	return boolAttribute(block, "always", false)
+}
+
+// resolveNeeds turns the block names in the needs attribute of every block into block indexes
+func resolveNeeds(blocks []Block) ([][]int, error) {
+	byName := make(map[string][]int)
+	for i, block := range blocks {
+		if block.Name != "" {
+			byName[block.Name] = append(byName[block.Name], i)
+		}
+	}
+
+	needs := make([][]int, len(blocks))
+	for i, block := range blocks {
+		for _, name := range block.Needs {
+			matches := byName[name]
+			switch {
+			case len(matches) == 0:
+				return nil, &DependencyError{Index: i + 1, Name: block.Name, Need: name, Reason: "no block has that name"}
+			case len(matches) > 1:
+				return nil, &DependencyError{Index: i + 1, Name: block.Name, Need: name, Reason: "more than one block has that name"}
+			}
+			needs[i] = append(needs[i], matches[0])
+		}
+	}
+	return needs, nil
+}
+
+// dependencyOrder sorts the blocks so that every block comes after the blocks it needs,
+// keeping the readme order otherwise
+func dependencyOrder(blocks []Block, needs [][]int) ([]int, error) {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(blocks))
+	var order []int
+	var path []int // blocks being visited, to name a cycle when one is found
+
+	var visit func(i int) error
+	visit = func(i int) error {
+		switch state[i] {
+		case visited:
+			return nil
+		case visiting:
+			var cycle []string
+			for j := len(path) - 1; j >= 0; j-- {
+				cycle = append([]string{blocks[path[j]].Name}, cycle...)
+				if path[j] == i {
+					break
+				}
+			}
+			return &CycleError{Blocks: append(cycle, blocks[i].Name)}
+		}
+
+		state[i] = visiting
+		path = append(path, i)
+		for _, need := range needs[i] {
+			if err := visit(need); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[i] = visited
+		order = append(order, i)
+		return nil
+	}
+
+	for i := range blocks {
+		if err := visit(i); err != nil {
+			return nil, err
+		}
+	}
+	return order, nil
+}
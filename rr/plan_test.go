@@ -0,0 +1,233 @@
+package rr
+
+import (
+	"errors"
+	"testing"
+)
+
+// blockNames returns the names of the planned blocks in order
+func blockNames(blocks []Block, order []int) []string {
+	var names []string
+	for _, i := range order {
+		names = append(names, blocks[i].Name)
+	}
+	return names
+}
+
+func equalNames(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestPlan_RunsNeededBlocksFirst(t *testing.T) {
+	doc := Parse(`<!-- RR[Deploy]{needs="Build,Test"}
+./deploy.sh
+-->
+<!-- RR[Test]{needs=Build}
+make test
+-->
+<!-- RR[Build]
+make
+-->
+<!-- RR[Docs]
+make docs
+-->`)
+
+	order, err := plan(doc.Blocks, Filter{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []string{"Build", "Test", "Deploy", "Docs"}
+	if names := blockNames(doc.Blocks, order); !equalNames(names, expected) {
+		t.Errorf("Expected order %v, got %v", expected, names)
+	}
+}
+
+func TestPlan_OnlyPullsInPrerequisites(t *testing.T) {
+	doc := Parse(`<!-- RR[Install]
+npm install
+-->
+<!-- RR[Build]{needs=Install}
+npm run build
+-->
+<!-- RR[Lint]
+npm run lint
+-->
+<!-- RR[Serve]{needs=Build}
+npm start
+-->`)
+
+	filter, _ := NewFilter(nil, []string{"Serve"}, nil, nil)
+	order, err := plan(doc.Blocks, filter)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []string{"Install", "Build", "Serve"}
+	if names := blockNames(doc.Blocks, order); !equalNames(names, expected) {
+		t.Errorf("Expected order %v, got %v", expected, names)
+	}
+}
+
+func TestPlan_SkippedPrerequisitesAreLeftOut(t *testing.T) {
+	doc := Parse("<!-- RR[Install]\nnpm install\n-->\n<!-- RR[Build]{needs=Install}\nnpm run build\n-->")
+
+	filter, _ := NewFilter(nil, []string{"Build"}, []string{"Install"}, nil)
+	order, err := plan(doc.Blocks, filter)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if names := blockNames(doc.Blocks, order); !equalNames(names, []string{"Build"}) {
+		t.Errorf("Expected only Build, got %v", names)
+	}
+}
+
+func TestPlan_Cycle(t *testing.T) {
+	doc := Parse(`<!-- RR[Setup]
+true
+-->
+<!-- RR[A]{needs="Setup,C"}
+true
+-->
+<!-- RR[B]{needs=A}
+true
+-->
+<!-- RR[C]{needs=B}
+true
+-->`)
+
+	_, err := plan(doc.Blocks, Filter{})
+
+	var cycleErr *CycleError
+	if !errors.As(err, &cycleErr) {
+		t.Fatalf("Expected CycleError, got %v", err)
+	}
+	if err.Error() != "dependency cycle between blocks: A -> C -> B -> A" {
+		t.Errorf("Unexpected error message: %s", err.Error())
+	}
+}
+
+func TestPlan_SelfCycle(t *testing.T) {
+	doc := Parse("<!-- RR[Loop]{needs=Loop}\ntrue\n-->")
+
+	_, err := plan(doc.Blocks, Filter{})
+
+	var cycleErr *CycleError
+	if !errors.As(err, &cycleErr) || !equalNames(cycleErr.Blocks, []string{"Loop", "Loop"}) {
+		t.Errorf("Expected cycle Loop -> Loop, got %v", err)
+	}
+}
+
+func TestPlan_UnknownOrAmbiguousNeed(t *testing.T) {
+	for _, content := range []string{
+		"<!-- RR[Build]{needs=Missing}\ntrue\n-->",
+		"<!-- RR[Twice]\ntrue\n-->\n<!-- RR[Twice]\ntrue\n-->\n<!-- RR[Build]{needs=Twice}\ntrue\n-->",
+	} {
+		_, err := plan(Parse(content).Blocks, Filter{})
+
+		var dependencyErr *DependencyError
+		if !errors.As(err, &dependencyErr) || dependencyErr.Name != "Build" {
+			t.Errorf("Expected DependencyError for block Build, got %v", err)
+		}
+	}
+}
+
+func TestPlan_InvalidDuration(t *testing.T) {
+	for _, attributes := range []string{"timeout=1", "command-timeout=soon", "ready-timeout=-5s"} {
+		doc := Parse("<!-- RR[Build]\ntrue\n-->\n<!-- RR[Slow]{" + attributes + "}\ntrue\n-->")
+		filter, _ := NewFilter(nil, []string{"Build"}, nil, nil)
+
+		_, err := plan(doc.Blocks, filter)
+
+		var attributeErr *AttributeError
+		if !errors.As(err, &attributeErr) || attributeErr.Index != 2 || attributeErr.Name != "Slow" {
+			t.Errorf("Expected AttributeError for block Slow with %s, got %v", attributes, err)
+		}
+	}
+}
+
+func TestPlan_InvalidRetries(t *testing.T) {
+	for _, attributes := range []string{"retries=three", "retries=-1", "retries=2 backoff=2"} {
+		doc := Parse("<!-- RR[Pull]{" + attributes + "}\ntrue\n-->")
+
+		_, err := plan(doc.Blocks, Filter{})
+
+		var attributeErr *AttributeError
+		if !errors.As(err, &attributeErr) || attributeErr.Name != "Pull" {
+			t.Errorf("Expected AttributeError for %s, got %v", attributes, err)
+		}
+	}
+}
+
+func TestPlan_UnknownFailurePolicy(t *testing.T) {
+	doc := Parse("<!-- RR[Stop]{on-error=contine}\ntrue\n-->")
+
+	_, err := plan(doc.Blocks, Filter{})
+
+	var attributeErr *AttributeError
+	if !errors.As(err, &attributeErr) || attributeErr.Attribute != "on-error" || attributeErr.Value != "contine" {
+		t.Errorf("Expected AttributeError for on-error=contine, got %v", err)
+	}
+}
+
+func TestPlan_CleanupBlocksRunLast(t *testing.T) {
+	doc := Parse("<!-- RR[Start]\ntrue\n-->\n" +
+		"<!-- RR[Cleanup]{always needs=Start}\ntrue\n-->\n" +
+		"<!-- RR[Test]\ntrue\n-->")
+
+	order, err := plan(doc.Blocks, Filter{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if names := blockNames(doc.Blocks, order); !equalNames(names, []string{"Start", "Test", "Cleanup"}) {
+		t.Errorf("Expected the cleanup block last, got %v", names)
+	}
+}
+
+func TestPlan_CleanupBlocksRunWithAnySelection(t *testing.T) {
+	doc := Parse("<!-- RR[Start]\ntrue\n-->\n" +
+		"<!-- RR[Test]{test}\ntrue\n-->\n" +
+		"<!-- RR[Stop]{always needs=Start}\ntrue\n-->\n" +
+		"<!-- RR[Remove]{always}\ntrue\n-->")
+
+	byNumber, _ := NewFilter([]string{"2"}, nil, nil, nil)
+	skipping, _ := NewFilter(nil, []string{"Test"}, []string{"Remove"}, nil)
+	tests := []struct {
+		name     string
+		filter   Filter
+		expected []string
+	}{
+		{"block number", byNumber, []string{"Test", "Stop", "Remove"}},
+		{"tests", Filter{}.Tests(), []string{"Test", "Stop", "Remove"}},
+		{"skipped cleanup block", skipping, []string{"Test", "Stop"}},
+	}
+
+	for _, tt := range tests {
+		order, err := plan(doc.Blocks, tt.filter)
+		if err != nil {
+			t.Fatalf("%s: unexpected error: %v", tt.name, err)
+		}
+		if names := blockNames(doc.Blocks, order); !equalNames(names, tt.expected) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.expected, names)
+		}
+	}
+}
+
+func TestPlan_BlockCantNeedCleanupBlock(t *testing.T) {
+	doc := Parse("<!-- RR[Cleanup]{always}\ntrue\n-->\n<!-- RR[Test]{needs=Cleanup}\ntrue\n-->")
+
+	_, err := plan(doc.Blocks, Filter{})
+	var depErr *DependencyError
+	if !errors.As(err, &depErr) || depErr.Need != "Cleanup" {
+		t.Errorf("Expected DependencyError for a block that needs a cleanup block, got %v", err)
+	}
+}
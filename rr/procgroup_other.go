@@ -0,0 +1,40 @@
+//go:build !(darwin || dragonfly || freebsd || linux || netbsd || openbsd)
+
+package rr
+
+import (
+	"io"
+	"os"
+	"os/exec"
+)
+
+// startProcessGroup is a no-op on platforms without process groups
+func startProcessGroup(cmd *exec.Cmd, stdin io.Reader) *os.File {
+	return nil
+}
+
+// restoreForeground is a no-op on platforms without process groups
+func restoreForeground(tty *os.File) {}
+
+// killProcessGroup kills the given process. Processes it started may keep running.
+func killProcessGroup(process *os.Process) error {
+	return process.Kill()
+}
+
+// terminateProcessGroup kills the given process, as there is no way to ask it to exit
+func terminateProcessGroup(process *os.Process) error {
+	return process.Kill()
+}
+
+// processGroupExists always reports false, as there are no process groups to look for
+func processGroupExists(process *os.Process) bool {
+	return false
+}
+
+// signalProcessGroup kills the given process, as signals can't be forwarded
+func signalProcessGroup(process *os.Process, sig os.Signal) error {
+	return process.Kill()
+}
+
+// joinProcessGroup is a no-op on platforms without process groups
+func joinProcessGroup(cmd *exec.Cmd, leader *os.Process) {}
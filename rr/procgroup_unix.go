@@ -0,0 +1,83 @@
+//go:build darwin || dragonfly || freebsd || linux || netbsd || openbsd
+
+package rr
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"unsafe"
+)
+
+// startProcessGroup makes cmd the leader of a new process group, so the commands started by
+// the shell can be killed together. When stdin is the terminal and we are in the foreground,
+// the new group is moved to the foreground so interactive commands can still read from it.
+// It returns the terminal to hand back with restoreForeground, or nil.
+func startProcessGroup(cmd *exec.Cmd, stdin io.Reader) *os.File {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	tty, ok := stdin.(*os.File)
+	if !ok {
+		return nil
+	}
+	pgrp, err := foregroundGroup(tty)
+	if err != nil || pgrp != syscall.Getpgrp() {
+		return nil
+	}
+
+	cmd.SysProcAttr.Foreground = true
+	cmd.SysProcAttr.Ctty = int(tty.Fd())
+	return tty
+}
+
+// restoreForeground moves our own process group back to the foreground of the terminal
+func restoreForeground(tty *os.File) {
+	if tty == nil {
+		return
+	}
+	// We are a background process until this succeeds, and would be stopped for trying
+	signal.Ignore(syscall.SIGTTOU)
+	defer signal.Reset(syscall.SIGTTOU)
+
+	pgrp := int32(syscall.Getpgrp())
+	syscall.Syscall(syscall.SYS_IOCTL, tty.Fd(), uintptr(syscall.TIOCSPGRP), uintptr(unsafe.Pointer(&pgrp)))
+}
+
+// killProcessGroup kills the process group led by the given process
+func killProcessGroup(process *os.Process) error {
+	return syscall.Kill(-process.Pid, syscall.SIGKILL)
+}
+
+// foregroundGroup returns the foreground process group of the terminal
+func foregroundGroup(tty *os.File) (int, error) {
+	var pgrp int32
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, tty.Fd(), uintptr(syscall.TIOCGPGRP), uintptr(unsafe.Pointer(&pgrp))); errno != 0 {
+		return 0, errno
+	}
+	return int(pgrp), nil
+}
+
+// terminateProcessGroup asks the process group led by the given process to exit
+func terminateProcessGroup(process *os.Process) error {
+	return syscall.Kill(-process.Pid, syscall.SIGTERM)
+}
+
+// processGroupExists reports whether a process of the group led by the given process is still around
+func processGroupExists(process *os.Process) bool {
+	return syscall.Kill(-process.Pid, 0) == nil
+}
+
+// signalProcessGroup sends a signal to the process group led by the given process
+func signalProcessGroup(process *os.Process, sig os.Signal) error {
+	if s, ok := sig.(syscall.Signal); ok {
+		return syscall.Kill(-process.Pid, s)
+	}
+	return process.Signal(sig)
+}
+
+// joinProcessGroup makes cmd join the process group led by the given process
+func joinProcessGroup(cmd *exec.Cmd, leader *os.Process) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true, Pgid: leader.Pid}
+}
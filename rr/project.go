@@ -0,0 +1,60 @@
+package rr
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// ResolveProjectDir turns the given project path into an absolute directory.
+// An empty path resolves to the current working directory.
+func ResolveProjectDir(projectPath string) (string, error) {
+	if projectPath == "" {
+		workDir, err := os.Getwd()
+		if err != nil {
+			return "", &ProjectPathError{Path: ".", Err: err}
+		}
+		return workDir, nil
+	}
+
+	workDir, err := filepath.Abs(projectPath)
+	if err != nil {
+		return "", &ProjectPathError{Path: projectPath, Err: err}
+	}
+
+	if _, err := os.Stat(workDir); os.IsNotExist(err) {
+		return "", &ProjectPathError{Path: workDir}
+	}
+
+	return workDir, nil
+}
+
+// LoadProject parses the readme in the given project directory
+func LoadProject(workDir string) (Document, error) {
+	readmePath, exists := FindReadme(workDir)
+	if !exists {
+		return Document{}, &ReadmeNotFoundError{Dir: workDir}
+	}
+
+	content, err := os.ReadFile(readmePath)
+	if err != nil {
+		return Document{}, &ReadmeNotFoundError{Dir: workDir, Err: err}
+	}
+
+	return Parse(string(content)), nil
+}
+
+// FindReadme returns the path of the readme file in the given directory
+func FindReadme(workDir string) (string, bool) {
+	readmePaths := []string{
+		filepath.Join(workDir, "readme.md"),
+		filepath.Join(workDir, "README.md"),
+	}
+
+	for _, path := range readmePaths {
+		if _, err := os.Stat(path); err == nil {
+			return path, true
+		}
+	}
+
+	return "", false
+}
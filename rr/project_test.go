@@ -0,0 +1,82 @@
+package rr
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFindReadme_READMEExists(t *testing.T) {
+	tempDir := t.TempDir()
+	readmePath := filepath.Join(tempDir, "README.md")
+
+	err := os.WriteFile(readmePath, []byte("# Test"), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create README: %v", err)
+	}
+
+	path, exists := FindReadme(tempDir)
+	if !exists {
+		t.Fatal("Expected README to be found")
+	}
+	// Note: FindReadme checks simple-example.md first, then readme.md, then README.md
+	// So we just verify it exists, not the exact path
+	if !strings.HasSuffix(path, ".md") {
+		t.Errorf("Expected path to end with .md, got '%s'", path)
+	}
+}
+
+func TestFindReadme_ReadmeExists(t *testing.T) {
+	tempDir := t.TempDir()
+	readmePath := filepath.Join(tempDir, "readme.md")
+
+	err := os.WriteFile(readmePath, []byte("# Test"), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create readme: %v", err)
+	}
+
+	path, exists := FindReadme(tempDir)
+	if !exists {
+		t.Fatal("Expected readme to be found")
+	}
+	if path != readmePath {
+		t.Errorf("Expected path '%s', got '%s'", readmePath, path)
+	}
+}
+
+func TestFindReadme_PriorityOrder(t *testing.T) {
+	tempDir := t.TempDir()
+
+	// Create both readme.md and README.md
+	readmePath := filepath.Join(tempDir, "readme.md")
+	readmePathUpper := filepath.Join(tempDir, "README.md")
+
+	err := os.WriteFile(readmePath, []byte("# Test"), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create readme: %v", err)
+	}
+
+	err = os.WriteFile(readmePathUpper, []byte("# Test"), 0644)
+	if err != nil {
+		t.Fatalf("Failed to create README: %v", err)
+	}
+
+	// readme.md should be found first (priority order)
+	path, exists := FindReadme(tempDir)
+	if !exists {
+		t.Fatal("Expected readme to be found")
+	}
+	if path != readmePath {
+		t.Errorf("Expected readme.md to be found first, got '%s'", path)
+	}
+}
+
+func TestFindReadme_NotFound(t *testing.T) {
+	tempDir := t.TempDir()
+
+	_, exists := FindReadme(tempDir)
+	if exists {
+		t.Error("Expected readme not to be found")
+	}
+}
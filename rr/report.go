@@ -0,0 +1,131 @@
+package rr
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// testName names the block of a result in test reports
+func testName(result BlockResult) string {
+	if result.Name != "" {
+		return result.Name
+	}
+	return fmt.Sprintf("block %d", result.Index)
+}
+
+// skipReason explains why a block of a test run didn't run
+func skipReason(status BlockStatus) string {
+	switch status {
+	case StatusSatisfied:
+		return "already satisfied"
+	case StatusCompleted:
+		return "completed in the last run"
+	}
+	return "not run"
+}
+
+// failureDetails describes why a block failed: the diff of its output if it didn't print what it
+// should have, otherwise the error itself
+func failureDetails(err *BlockFailedError) string {
+	var mismatch *OutputMismatchError
+	if errors.As(err, &mismatch) {
+		return unifiedDiff(mismatch.Expected, mismatch.Actual)
+	}
+	return err.Error()
+}
+
+// junitTestSuites is the root element of a JUnit XML report
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Skipped  int             `xml:"skipped,attr"`
+	Time     string          `xml:"time,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Body    string `xml:",cdata"`
+}
+
+// WriteJUnit writes the results of a run as a JUnit XML report with one test case per block.
+// Failed blocks are failures, cancelled blocks errors, and blocks that didn't run are skipped.
+func WriteJUnit(w io.Writer, suite string, results []BlockResult) error {
+	report := junitTestSuite{Name: suite, Tests: len(results)}
+	var total float64
+	for _, result := range results {
+		seconds := result.Duration.Seconds()
+		total += seconds
+		testCase := junitTestCase{Name: testName(result), ClassName: suite, Time: fmt.Sprintf("%.3f", seconds)}
+		switch result.Status {
+		case StatusSucceeded:
+		case StatusFailed:
+			report.Failures++
+			testCase.Failure = &junitMessage{Message: result.Err.Error(), Body: failureDetails(result.Err)}
+		case StatusCancelled:
+			report.Errors++
+			testCase.Error = &junitMessage{Message: "cancelled"}
+		default:
+			report.Skipped++
+			testCase.Skipped = &junitMessage{Message: skipReason(result.Status)}
+		}
+		report.Cases = append(report.Cases, testCase)
+	}
+	report.Time = fmt.Sprintf("%.3f", total)
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(junitTestSuites{Suites: []junitTestSuite{report}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteTAP writes the results of a run in the Test Anything Protocol (version 13) with one test per block.
+// Blocks that didn't run are reported with a SKIP directive, and why a block failed follows as diagnostics.
+func WriteTAP(w io.Writer, results []BlockResult) error {
+	var out strings.Builder
+	fmt.Fprintf(&out, "TAP version 13\n1..%d\n", len(results))
+	for n, result := range results {
+		// A # would start a directive, so it is escaped in the description
+		name := strings.ReplaceAll(testName(result), "#", `\#`)
+		switch result.Status {
+		case StatusSucceeded:
+			fmt.Fprintf(&out, "ok %d - %s\n", n+1, name)
+		case StatusFailed:
+			fmt.Fprintf(&out, "not ok %d - %s\n", n+1, name)
+			for _, line := range strings.Split(strings.TrimRight(failureDetails(result.Err), "\n"), "\n") {
+				fmt.Fprintf(&out, "# %s\n", line)
+			}
+		case StatusCancelled:
+			fmt.Fprintf(&out, "not ok %d - %s\n# cancelled\n", n+1, name)
+		default:
+			fmt.Fprintf(&out, "ok %d - %s # SKIP %s\n", n+1, name, skipReason(result.Status))
+		}
+	}
+	_, err := io.WriteString(w, out.String())
+	return err
+}
@@ -0,0 +1,64 @@
+package rr
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+)
+
+// reportResults are the results of a run with a block of every kind of outcome
+func reportResults() []BlockResult {
+	mismatch := newBlockFailedError(2, Block{Name: "Drifted"}, &OutputMismatchError{Match: "exact", Expected: "moon", Actual: "world"})
+	return []BlockResult{
+		{Index: 1, Name: "Setup", Status: StatusSucceeded, Duration: 1500 * time.Millisecond},
+		{Index: 2, Name: "Drifted", Status: StatusFailed, Err: mismatch, Duration: time.Second},
+		{Index: 3, Status: StatusSatisfied},
+		{Index: 4, Name: "Slow #1", Status: StatusCancelled},
+	}
+}
+
+func TestWriteJUnit(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteJUnit(&out, "project", reportResults()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var report junitTestSuites
+	if err := xml.Unmarshal(out.Bytes(), &report); err != nil {
+		t.Fatalf("Expected valid XML, got %v:\n%s", err, out.String())
+	}
+	suite := report.Suites[0]
+	if suite.Name != "project" || suite.Tests != 4 || suite.Failures != 1 || suite.Errors != 1 || suite.Skipped != 1 || suite.Time != "2.500" {
+		t.Errorf("Unexpected suite totals: %+v", suite)
+	}
+	if suite.Cases[0].Name != "Setup" || suite.Cases[0].Time != "1.500" || suite.Cases[0].Failure != nil {
+		t.Errorf("Unexpected passing test case: %+v", suite.Cases[0])
+	}
+	if failure := suite.Cases[1].Failure; failure == nil || !strings.Contains(failure.Body, "-moon\n+world\n") {
+		t.Errorf("Expected the failure to hold the output diff, got %+v", failure)
+	}
+	if suite.Cases[2].Name != "block 3" || suite.Cases[2].Skipped == nil || suite.Cases[2].Skipped.Message != "already satisfied" {
+		t.Errorf("Expected the satisfied block to be skipped, got %+v", suite.Cases[2])
+	}
+	if suite.Cases[3].Error == nil {
+		t.Errorf("Expected the cancelled block to be an error, got %+v", suite.Cases[3])
+	}
+}
+
+func TestWriteTAP(t *testing.T) {
+	var out bytes.Buffer
+	if err := WriteTAP(&out, reportResults()); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := "TAP version 13\n1..4\n" +
+		"ok 1 - Setup\n" +
+		"not ok 2 - Drifted\n# --- expected\n# +++ actual\n# @@ -1,1 +1,1 @@\n# -moon\n# +world\n" +
+		"ok 3 - block 3 # SKIP already satisfied\n" +
+		"not ok 4 - Slow \\#1\n# cancelled\n"
+	if out.String() != expected {
+		t.Errorf("Unexpected TAP output:\n%s\nexpected:\n%s", out.String(), expected)
+	}
+}
@@ -0,0 +1,88 @@
+package rr
+
+import (
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+)
+
+// BlockStatus is the outcome of a block in a run
+type BlockStatus string
+
+const (
+	StatusSucceeded BlockStatus = "succeeded"
+	StatusFailed    BlockStatus = "failed"
+	StatusSkipped   BlockStatus = "skipped"   // declined, or not reached after the run was aborted
+	StatusCompleted BlockStatus = "completed" // not run again, as it completed in the run that was resumed
+	StatusSatisfied BlockStatus = "satisfied" // not run, as its creates or unless guard says it is already done
+	StatusCancelled BlockStatus = "cancelled" // stopped because the run was interrupted or another block running alongside it failed
+)
+
+// BlockResult is the outcome of a single selected block
+type BlockResult struct {
+	Index  int    // 1-based block number
+	Name   string // block name, may be empty
+	Status BlockStatus
+	Err    *BlockFailedError // set when Status is StatusFailed
+
+	// Duration is how long the block ran, zero for blocks that didn't run
+	Duration time.Duration
+}
+
+// Failure policies for the on-error block attribute
+const (
+	OnErrorAbort    = "abort"    // stop the run
+	OnErrorContinue = "continue" // carry on with the next block
+	OnErrorPrompt   = "prompt"   // ask whether to carry on
+)
+
+// failurePolicy returns how a failure of the block is handled. The on-error attribute
+// overrides the KeepGoing option; unknown values are reported by validateAttributes.
+func failurePolicy(block Block, keepGoing bool) string {
+	switch policy := block.Attributes["on-error"]; policy {
+	case OnErrorAbort, OnErrorContinue, OnErrorPrompt:
+		return policy
+	}
+	if keepGoing {
+		return OnErrorContinue
+	}
+	return OnErrorAbort
+}
+
+// printSummary writes a table with the outcome of every selected block
+func printSummary(out io.Writer, results []BlockResult) {
+	if len(results) == 0 {
+		return
+	}
+
+	counts := make(map[BlockStatus]int)
+	fmt.Fprintln(out, "\n--- Summary ---")
+	writer := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "#\tNAME\tRESULT")
+	for _, result := range results {
+		counts[result.Status]++
+		name := result.Name
+		if name == "" {
+			name = "(unnamed)"
+		}
+		status := string(result.Status)
+		if result.Err != nil && result.Err.Command != "" {
+			status = fmt.Sprintf("%s (exit code %d: %s)", status, result.Err.ExitCode, result.Err.Command)
+		}
+		fmt.Fprintf(writer, "%d\t%s\t%s\n", result.Index, name, status)
+	}
+	writer.Flush()
+	fmt.Fprintf(out, "%d block(s): %d succeeded, %d failed, %d skipped",
+		len(results), counts[StatusSucceeded], counts[StatusFailed], counts[StatusSkipped])
+	if counts[StatusCancelled] > 0 {
+		fmt.Fprintf(out, ", %d cancelled", counts[StatusCancelled])
+	}
+	if counts[StatusSatisfied] > 0 {
+		fmt.Fprintf(out, ", %d already satisfied", counts[StatusSatisfied])
+	}
+	if counts[StatusCompleted] > 0 {
+		fmt.Fprintf(out, ", %d completed before", counts[StatusCompleted])
+	}
+	fmt.Fprintln(out)
+}
@@ -0,0 +1,149 @@
+package rr
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RunLogs keeps the logs of runs in a .rr-runs directory in the project directory. Every run gets a
+// directory named after the time it started, with a log of each block's output and a manifest.json.
+type RunLogs struct {
+	path string
+}
+
+// RunManifest describes a logged run and the outcome of its blocks
+type RunManifest struct {
+	Started    time.Time       `json:"started"`
+	Finished   *time.Time      `json:"finished,omitempty"`
+	Status     string          `json:"status"` // running, succeeded, failed or interrupted
+	Error      string          `json:"error,omitempty"`
+	DurationMS int64           `json:"duration_ms"`
+	Blocks     []ManifestBlock `json:"blocks"`
+}
+
+// ManifestBlock is the outcome of a selected block of a logged run
+type ManifestBlock struct {
+	Index      int    `json:"index"`
+	Name       string `json:"name,omitempty"`
+	Status     string `json:"status"`
+	DurationMS int64  `json:"duration_ms"`
+	Log        string `json:"log,omitempty"` // file name of the block's log, if it ran
+	Command    string `json:"command,omitempty"`
+	ExitCode   *int   `json:"exit_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+// NewRunLogs returns the run logs of the given project directory
+func NewRunLogs(workDir string) *RunLogs {
+	return &RunLogs{path: filepath.Join(workDir, ".rr-runs")}
+}
+
+// runLog is the log directory of the current run
+type runLog struct {
+	dir      string
+	mu       sync.Mutex
+	files    map[int]*os.File // open block logs by 1-based block number
+	manifest RunManifest
+}
+
+// start creates the log directory of a run that starts now
+func (l *RunLogs) start(started time.Time) (*runLog, error) {
+	if err := os.MkdirAll(l.path, 0755); err != nil {
+		return nil, err
+	}
+
+	// Two runs may start within the same second
+	name := started.Format("20060102-150405")
+	dir := filepath.Join(l.path, name)
+	for n := 2; ; n++ {
+		err := os.Mkdir(dir, 0755)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		dir = filepath.Join(l.path, fmt.Sprintf("%s-%d", name, n))
+	}
+
+	log := &runLog{dir: dir, files: make(map[int]*os.File), manifest: RunManifest{Started: started, Status: "running", Blocks: []ManifestBlock{}}}
+	return log, log.writeManifest()
+}
+
+// blockLog opens the log of a block, e.g. 03-install-deps.log. The log stays open until the run ends,
+// as background blocks carry on writing to it.
+func (l *runLog) blockLog(index int, name string) (io.Writer, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if file, exists := l.files[index]; exists {
+		return file, nil
+	}
+	file, err := os.Create(filepath.Join(l.dir, logFileName(index, name)))
+	if err != nil {
+		return nil, err
+	}
+	l.files[index] = file
+	return file, nil
+}
+
+// logFileName names the log of a block after its number and name
+func logFileName(index int, name string) string {
+	slug := strings.Trim(regexp.MustCompile(`[^a-z0-9]+`).ReplaceAllString(strings.ToLower(name), "-"), "-")
+	if slug == "" {
+		slug = "block"
+	}
+	return fmt.Sprintf("%02d-%s.log", index, slug)
+}
+
+// update records the outcome of the blocks so far in the manifest
+func (l *runLog) update(results []BlockResult) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.manifest.Blocks = l.manifest.Blocks[:0]
+	for _, result := range results {
+		block := ManifestBlock{Index: result.Index, Name: result.Name, Status: string(result.Status), DurationMS: result.Duration.Milliseconds()}
+		if file, exists := l.files[result.Index]; exists {
+			block.Log = filepath.Base(file.Name())
+		}
+		if result.Err != nil {
+			block.Error = result.Err.Error()
+			if result.Err.Command != "" {
+				exitCode := result.Err.ExitCode
+				block.Command, block.ExitCode = result.Err.Command, &exitCode
+			}
+		}
+		l.manifest.Blocks = append(l.manifest.Blocks, block)
+	}
+	return l.writeManifest()
+}
+
+// finish closes the block logs and records how the run ended in the manifest
+func (l *runLog) finish(results []BlockResult, err error, finished time.Time) error {
+	l.mu.Lock()
+	for _, file := range l.files {
+		file.Close()
+	}
+	end := runEndEvent(err, finished.Sub(l.manifest.Started))
+	l.manifest.Finished = &finished
+	l.manifest.Status, l.manifest.Error, l.manifest.DurationMS = end.Status, end.Error, *end.DurationMS
+	l.mu.Unlock()
+
+	return l.update(results)
+}
+
+// writeManifest saves the manifest, the caller holds l.mu or owns l
+func (l *runLog) writeManifest() error {
+	content, err := json.MarshalIndent(l.manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(l.dir, "manifest.json"), append(content, '\n'), 0644)
+}
@@ -0,0 +1,87 @@
+package rr
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLogFileName(t *testing.T) {
+	tests := map[string]string{
+		"Install deps":       "03-install-deps.log",
+		"":                   "03-block.log",
+		"  Build/Test (CI) ": "03-build-test-ci.log",
+	}
+	for name, expected := range tests {
+		if got := logFileName(3, name); got != expected {
+			t.Errorf("logFileName(3, %q) = %q, expected %q", name, got, expected)
+		}
+	}
+}
+
+func TestRun_Logs(t *testing.T) {
+	tempDir := t.TempDir()
+	doc := Parse("<!-- RR[Install deps]\necho installing\necho warning >&2\n-->\n<!-- RR[Declined]\ntrue\n-->\n<!-- RR\nexit 3\n-->")
+	confirm := func(block Block, blockNum, totalBlocks int) bool { return block.Name != "Declined" }
+
+	var stdout bytes.Buffer
+	runner := NewRunner(Options{Stdout: &stdout, Confirm: confirm, KeepGoing: true, Logs: NewRunLogs(tempDir)})
+	if err := runner.Run(context.Background(), doc); err == nil {
+		t.Fatal("Expected the run to fail")
+	}
+
+	runs, _ := filepath.Glob(filepath.Join(tempDir, ".rr-runs", "*"))
+	if len(runs) != 1 {
+		t.Fatalf("Expected one run directory, got %v", runs)
+	}
+	if !strings.Contains(stdout.String(), "Logs of this run: "+runs[0]) {
+		t.Errorf("Expected the log directory to be reported, got %q", stdout.String())
+	}
+
+	log, err := os.ReadFile(filepath.Join(runs[0], "01-install-deps.log"))
+	if err != nil || !strings.Contains(string(log), "installing\n") || !strings.Contains(string(log), "warning\n") {
+		t.Errorf("Expected the block's stdout and stderr in its log, got %q (%v)", log, err)
+	}
+	if !strings.Contains(stdout.String(), "installing\n") {
+		t.Errorf("Expected the output to be streamed as well, got %q", stdout.String())
+	}
+
+	content, err := os.ReadFile(filepath.Join(runs[0], "manifest.json"))
+	if err != nil {
+		t.Fatalf("Expected a manifest: %v", err)
+	}
+	var manifest RunManifest
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		t.Fatalf("Expected a valid manifest: %v", err)
+	}
+	if manifest.Status != "failed" || manifest.Finished == nil || len(manifest.Blocks) != 3 {
+		t.Fatalf("Unexpected manifest: %s", content)
+	}
+	if block := manifest.Blocks[1]; block.Status != string(StatusSkipped) || block.Log != "" {
+		t.Errorf("Expected the declined block to have no log, got %+v", block)
+	}
+	if block := manifest.Blocks[2]; block.Log != "03-block.log" || block.ExitCode == nil || *block.ExitCode != 3 || block.Command != "exit 3" {
+		t.Errorf("Expected the failed block's command and exit code, got %+v", block)
+	}
+}
+
+func TestRunLogs_SameSecond(t *testing.T) {
+	logs := NewRunLogs(t.TempDir())
+	started := time.Date(2026, 1, 5, 10, 0, 0, 0, time.UTC)
+	first, err := logs.start(started)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := logs.start(started)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if first.dir == second.dir || !strings.HasSuffix(second.dir, "-2") {
+		t.Errorf("Expected runs started in the same second to get their own directory, got %s and %s", first.dir, second.dir)
+	}
+}
@@ -0,0 +1,885 @@
+package rr
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Options configure a Runner. The zero value runs every block of a document with the standard
+// streams of the current process, asking on stdin before running any block.
+type Options struct {
+	// Stdin, Stdout and Stderr are used by the executed commands and for prompts.
+	// Nil streams default to those of the current process. A Stdin that isn't an *os.File
+	// is copied to the commands, which may consume input meant for later prompts.
+	Stdin  io.Reader
+	Stdout io.Writer
+	Stderr io.Writer
+
+	// Dir is the project directory the readme was loaded from. Blocks run in it, or in the directory
+	// their cwd attribute names relative to it. Empty means the current directory.
+	Dir string
+
+	// Filter selects the blocks to run. The zero Filter selects every block.
+	Filter Filter
+
+	// EnvVars are the variables loaded from a .env file, see LoadEnv
+	EnvVars map[string]string
+
+	// Trust runs every block without asking for approval
+	Trust bool
+	// Approvals remembers which blocks have been approved. Nil means nothing is remembered.
+	Approvals *ApprovalStore
+	// Confirm asks whether a block that hasn't been approved yet may run.
+	// Nil asks interactively on Stdin.
+	Confirm func(block Block, blockNum, totalBlocks int) bool
+
+	// ExportVars exports .env, global, captured and block variables to the commands' environment
+	ExportVars bool
+	// CleanEnv starts commands from an empty environment
+	CleanEnv bool
+	// InheritEnv lists the variables kept from the parent environment when CleanEnv is set
+	InheritEnv []string
+
+	// KeepGoing carries on with the remaining blocks after a block fails.
+	// The on-error block attribute overrides it.
+	KeepGoing bool
+
+	// Timeout limits how long each block may run, CommandTimeout how long each of its commands may run.
+	// Zero means no limit. The timeout and command-timeout block attributes override them.
+	Timeout        time.Duration
+	CommandTimeout time.Duration
+
+	// Retries is how often a failed command is run again before its block fails. The first retry
+	// waits Backoff (one second if zero), and every further retry waits twice as long as the one before.
+	// The retries and backoff block attributes override them.
+	Retries int
+	Backoff time.Duration
+
+	// Parallel is how many blocks of the same group may run at the same time.
+	// Values below 2 run every block on its own.
+	Parallel int
+
+	// Checkpoints records the blocks that completed, and the values they captured, as the run goes.
+	// The record is cleared when a run ends without failures. Nil records nothing.
+	Checkpoints *CheckpointStore
+	// Resume skips the blocks that completed in the checkpointed run and haven't changed since,
+	// and restores the values they captured. Cleanup blocks always run.
+	Resume bool
+
+	// Logs keeps a log of each block's output, and a manifest of the run, in a directory of its own
+	// for every run. Nil keeps no logs.
+	Logs *RunLogs
+
+	// Events is called for everything that happens in a run, one event at a time, see JSONEvents.
+	// Nil reports no events.
+	Events func(Event)
+}
+
+const (
+	defaultBackoff = time.Second     // wait before the first retry when no backoff is given
+	maxBackoff     = 5 * time.Minute // longest wait between two retries
+)
+
+// Runner runs the blocks of a parsed readme
+type Runner struct {
+	opts   Options
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+	input  *bufio.Reader // line reader over stdin for prompts and confirmations
+	line   chan lineRead // the read of the next input line, if one is in progress
+
+	results []BlockResult     // outcome of the selected blocks of the last run
+	globals map[string]string // global variables of the current run's document, which approvals depend on
+
+	backgroundMu sync.Mutex
+	background   []*backgroundBlock // background blocks started by the current run
+
+	stopMu   sync.Mutex
+	sessions map[*shellSession]bool // shells of the running blocks, which interruptions are forwarded to
+	signal   os.Signal              // the signal the current run was interrupted with
+	stopRead chan struct{}          // closed and replaced by every interruption, which ends waits for input
+	force    context.CancelFunc     // kills every running command of the current run
+
+	eventsMu sync.Mutex
+	runLog   *runLog // logs of the current run, if they are kept
+}
+
+// NewRunner creates a runner with the given options
+func NewRunner(opts Options) *Runner {
+	r := &Runner{opts: opts, stdin: opts.Stdin, stdout: opts.Stdout, stderr: opts.Stderr}
+	if r.stdin == nil {
+		r.stdin = os.Stdin
+	}
+	if r.stdout == nil {
+		r.stdout = os.Stdout
+	}
+	if r.stderr == nil {
+		r.stderr = os.Stderr
+	}
+	// Commands' output is copied to writers that aren't files in the background,
+	// while the runner keeps writing its own messages
+	var mu sync.Mutex
+	if _, isFile := r.stdout.(*os.File); !isFile {
+		r.stdout = lockedWriter{mu: &mu, w: r.stdout}
+	}
+	if _, isFile := r.stderr.(*os.File); !isFile {
+		r.stderr = lockedWriter{mu: &mu, w: r.stderr}
+	}
+	r.input = bufio.NewReader(r.stdin)
+	r.stopRead = make(chan struct{})
+	return r
+}
+
+// Run runs the selected blocks of doc, and the blocks they need, in the order they appear with
+// every block after the blocks it needs. It prints a summary of the outcome of every block. When
+// a block fails the run is aborted, unless the block's failure policy says otherwise; blocks that
+// need a failed block are skipped. A single failed block is returned as a *BlockFailedError,
+// several as a *RunFailedError. Background blocks are stopped when the run ends.
+//
+// Cleanup blocks, marked with the always attribute, run at the end however the run went. Once ctx
+// is cancelled the running blocks are stopped and only the cleanup blocks still run, to completion;
+// the run then returns the context's error, wrapped in a *BlockFailedError if a block was stopped.
+// A run stopped with Interrupt returns an *InterruptedError.
+func (r *Runner) Run(ctx context.Context, doc Document) error {
+	r.emit(Event{Type: EventRunStart, Blocks: len(doc.Blocks)})
+	start := time.Now()
+	err := r.run(ctx, doc)
+	r.emit(runEndEvent(err, time.Since(start)))
+
+	if r.runLog != nil {
+		if logErr := r.runLog.finish(r.results, err, time.Now()); logErr != nil {
+			fmt.Fprintf(r.stdout, "\nWarning: could not save the run's manifest: %v\n", logErr)
+		}
+		fmt.Fprintf(r.stdout, "Logs of this run: %s\n", r.runLog.dir)
+		r.runLog = nil
+	}
+	return err
+}
+
+// run does the work of Run
+func (r *Runner) run(ctx context.Context, doc Document) error {
+	r.results = nil
+	r.globals = doc.Globals
+	r.stopMu.Lock()
+	r.signal = nil
+	r.stopMu.Unlock()
+
+	order, err := plan(doc.Blocks, r.opts.Filter)
+	if err != nil {
+		return err
+	}
+
+	// Global variables and values captured by blocks are shared across the whole run
+	runVars := make(map[string]string)
+	for k, v := range doc.Globals {
+		runVars[k] = v
+	}
+	if err := r.askPrompts(runVars); err != nil {
+		var interruptedErr *InterruptedError
+		if errors.As(err, &interruptedErr) {
+			return err
+		}
+		return fmt.Errorf("error reading global variables: %w", err)
+	}
+
+	var checkpoint Checkpoint
+	if r.opts.Checkpoints != nil {
+		if r.opts.Resume {
+			if checkpoint, err = r.opts.Checkpoints.Load(); err != nil {
+				return fmt.Errorf("error reading the state of the last run: %w", err)
+			}
+			for k, v := range checkpoint.Captures {
+				runVars[k] = v
+			}
+		} else if err := r.opts.Checkpoints.Clear(); err != nil {
+			return fmt.Errorf("error clearing the state of the last run: %w", err)
+		}
+	}
+	if r.opts.Logs != nil {
+		if r.runLog, err = r.opts.Logs.start(time.Now()); err != nil {
+			// Don't fail the run because it can't be logged
+			fmt.Fprintf(r.stdout, "\nWarning: could not create the logs of the run: %v\n", err)
+		}
+	}
+
+	// Background blocks live until the run ends, however it ends
+	defer r.stopBackground()
+
+	var failures []*BlockFailedError
+	aborted := false
+	statuses := make(map[string]BlockStatus) // by block name, for the blocks of this run
+
+	// Once ctx is done or the run is interrupted only cleanup blocks run, with a context of their
+	// own so they can finish. A second interruption cancels both contexts.
+	runCtx, cancelRun := context.WithCancel(ctx)
+	defer cancelRun()
+	cleanupCtx, cancelCleanup := context.WithCancel(context.WithoutCancel(ctx))
+	defer cancelCleanup()
+	r.stopMu.Lock()
+	r.force = func() {
+		cancelRun()
+		cancelCleanup()
+	}
+	r.stopMu.Unlock()
+
+	var interrupted error
+	stopRun := func(err error) {
+		if interrupted == nil {
+			interrupted = err
+			aborted = true
+		}
+	}
+
+	for _, batch := range batches(doc.Blocks, order, r.opts.Parallel) {
+		if err := ctx.Err(); err != nil {
+			stopRun(err)
+		} else if sig := r.interruption(); sig != nil {
+			stopRun(&InterruptedError{Signal: sig})
+		}
+
+		// Approvals and prompts are asked one block at a time, before any block of the batch runs
+		results := make([]BlockResult, len(batch))
+		var jobs []blockJob
+		for k, i := range batch {
+			block := doc.Blocks[i]
+			results[k] = BlockResult{Index: i + 1, Name: block.Name, Status: StatusSkipped}
+			if sig := r.interruption(); sig != nil {
+				// e.g. while the previous block was asking for approval
+				stopRun(&InterruptedError{Signal: sig})
+			}
+			// Guards run like commands, so cleanup blocks check theirs with the cleanup context
+			guardCtx := runCtx
+			if interrupted != nil {
+				guardCtx = cleanupCtx
+			}
+			switch {
+			case aborted && !runsAlways(block):
+				// Blocks after an aborting failure are reported as skipped
+			case !runsAlways(block) && unmetNeed(block, statuses) != "":
+				fmt.Fprintf(r.stdout, "\nSkipping block %d: it needs %s, which did not succeed\n", i+1, unmetNeed(block, statuses))
+			case r.opts.Resume && !runsAlways(block) && checkpoint.IsCompleted(HashBlockWithGlobals(block, doc.Globals)):
+				fmt.Fprintf(r.stdout, "\nSkipping block %d: it completed in the last run\n", i+1)
+				results[k].Status = StatusCompleted
+				if r.opts.Checkpoints != nil {
+					checkpoint.complete(i+1, block, doc.Globals, runVars)
+				}
+			case !r.opts.Trust && !r.approve(block, i+1, len(doc.Blocks)):
+				// If trust is set, skip all hash operations and execute directly
+				fmt.Fprintln(r.stdout, "Skipping block...")
+			default:
+				if r.opts.Trust {
+					r.emit(Event{Type: EventApproval, Block: i + 1, Name: block.Name, Decision: DecisionTrusted})
+				}
+				job := blockJob{result: k, index: i, block: block}
+				// Work on a copy so prompt answers and captures don't leak into the caller's document
+				job.blockVars = mergeVariables(block.Variables)
+				if err := r.askPrompts(job.blockVars); err != nil {
+					job.err = err
+				} else if reason, err := r.alreadySatisfied(guardCtx, block, job.blockVars, runVars); err != nil {
+					job.err = err
+				} else if reason != "" {
+					fmt.Fprintf(r.stdout, "\nSkipping block %d: already satisfied, %s\n", i+1, reason)
+					results[k].Status = StatusSatisfied
+					continue
+				}
+				jobs = append(jobs, job)
+			}
+		}
+
+		cleaningUp := interrupted != nil
+		jobCtx := runCtx
+		if cleaningUp {
+			jobCtx = cleanupCtx
+		}
+		r.runJobs(jobCtx, jobs, runVars)
+
+		for _, job := range jobs {
+			result := &results[job.result]
+			result.Duration = job.duration
+			switch {
+			case job.err == nil:
+				result.Status = StatusSucceeded
+				if r.opts.Checkpoints != nil && !runsAlways(job.block) {
+					checkpoint.complete(job.index+1, job.block, doc.Globals, runVars)
+					if err := r.opts.Checkpoints.Save(checkpoint); err != nil {
+						// Don't fail the run because its progress can't be recorded
+						fmt.Fprintf(r.stdout, "\nWarning: could not save the state of the run: %v\n", err)
+					}
+				}
+			case job.cancelled:
+				result.Status = StatusCancelled
+			case jobCtx.Err() != nil || (!cleaningUp && r.interruption() != nil):
+				// Stopped because ctx is done or the run was interrupted
+				result.Status = StatusCancelled
+				stopRun(newBlockFailedError(job.index+1, job.block, job.err))
+			default:
+				blockErr := newBlockFailedError(job.index+1, job.block, job.err)
+				result.Status = StatusFailed
+				result.Err = blockErr
+				failures = append(failures, blockErr)
+				if !aborted {
+					aborted = !r.continueAfterFailure(job.block, blockErr)
+				}
+			}
+		}
+
+		for k, i := range batch {
+			if name := doc.Blocks[i].Name; name != "" {
+				statuses[name] = results[k].Status
+			}
+			r.emit(blockEndEvent(results[k]))
+		}
+		r.results = append(r.results, results...)
+		if r.runLog != nil {
+			_ = r.runLog.update(r.results)
+		}
+	}
+
+	r.stopBackground()
+	printSummary(r.stdout, r.results)
+
+	if r.opts.Checkpoints != nil && interrupted == nil && len(failures) == 0 {
+		// Nothing left to resume
+		_ = r.opts.Checkpoints.Clear()
+	}
+
+	if sig := r.interruption(); sig != nil {
+		return &InterruptedError{Signal: sig}
+	}
+	if interrupted != nil {
+		return interrupted
+	}
+	switch len(failures) {
+	case 0:
+		return nil
+	case 1:
+		return failures[0]
+	default:
+		return &RunFailedError{Failures: failures}
+	}
+}
+
+// Interrupt stops the current run, as when the user presses Ctrl-C. The signal is forwarded to the
+// process groups of the running commands, and once they have exited the run only goes on with
+// cleanup blocks. A second call kills the running commands, those of cleanup blocks included.
+// It is safe to call from another goroutine, e.g. one that receives signals.
+func (r *Runner) Interrupt(sig os.Signal) {
+	r.interrupt(sig, true)
+}
+
+// interrupt records that the run was interrupted with sig, forwarding it to the running commands
+// unless they already got it from the terminal. A second interruption kills them.
+func (r *Runner) interrupt(sig os.Signal, forward bool) {
+	r.stopMu.Lock()
+	defer r.stopMu.Unlock()
+
+	// Stop waiting for input. Questions asked from now on, e.g. by cleanup blocks, wait for the next interruption.
+	close(r.stopRead)
+	r.stopRead = make(chan struct{})
+
+	if r.signal != nil {
+		fmt.Fprintln(r.stdout, "\nInterrupted again, killing the running commands")
+		if r.force != nil {
+			r.force()
+		}
+		return
+	}
+
+	r.signal = sig
+	fmt.Fprintln(r.stdout, "\nInterrupted, only cleanup blocks run from now on. Interrupt again to kill the running commands.")
+	if forward {
+		for session := range r.sessions {
+			signalProcessGroup(session.cmd.Process, sig)
+		}
+	}
+}
+
+// noticeTerminalInterrupt makes sure that Ctrl-C at the terminal while a command of the session ran
+// has interrupted the run before the run goes on. The terminal sends SIGINT straight to the session's
+// process group, so we only hear of it through the session.
+func (r *Runner) noticeTerminalInterrupt(session *shellSession) {
+	session.syncInterrupts()
+}
+
+// interruption returns the signal the current run was interrupted with, or nil
+func (r *Runner) interruption() os.Signal {
+	r.stopMu.Lock()
+	defer r.stopMu.Unlock()
+	return r.signal
+}
+
+// trackSession registers the shell of a running block, so interruptions reach its commands.
+// Call the returned function once the shell is gone.
+func (r *Runner) trackSession(session *shellSession) func() {
+	r.stopMu.Lock()
+	defer r.stopMu.Unlock()
+	if r.sessions == nil {
+		r.sessions = make(map[*shellSession]bool)
+	}
+	r.sessions[session] = true
+	session.onInterrupt(func() { r.interrupt(os.Interrupt, false) })
+
+	return func() {
+		r.stopMu.Lock()
+		defer r.stopMu.Unlock()
+		delete(r.sessions, session)
+	}
+}
+
+// unmetNeed returns the name of a block needed by block that took part in the run but didn't succeed.
+// Needed blocks that were left out of the run, e.g. with a skip pattern, count as met.
+func unmetNeed(block Block, statuses map[string]BlockStatus) string {
+	for _, name := range block.Needs {
+		if status, ran := statuses[name]; ran && status != StatusSucceeded && status != StatusCompleted && status != StatusSatisfied {
+			return name
+		}
+	}
+	return ""
+}
+
+// Results returns the outcome of every selected block of the last run
+func (r *Runner) Results() []BlockResult {
+	return r.results
+}
+
+// continueAfterFailure reports the failure of a block and decides whether the run carries on
+func (r *Runner) continueAfterFailure(block Block, err *BlockFailedError) bool {
+	policy := failurePolicy(block, r.opts.KeepGoing)
+	if policy == OnErrorAbort {
+		// The caller reports the error the run ends with
+		return false
+	}
+	fmt.Fprintf(r.stdout, "\nError: %v\n", err)
+
+	switch policy {
+	case OnErrorContinue:
+		fmt.Fprintln(r.stdout, "Continuing with the remaining blocks...")
+		return true
+	case OnErrorPrompt:
+		fmt.Fprint(r.stdout, "Continue with the remaining blocks? (y/n): ")
+		input, readErr := r.readLine()
+		if readErr != nil {
+			fmt.Fprintf(r.stdout, "\nError reading input: %v\n", readErr)
+			return false
+		}
+		response := strings.TrimSpace(strings.ToLower(input))
+		return response == "y" || response == "yes"
+	default:
+		return false
+	}
+}
+
+// approve checks whether a block has been approved before, and otherwise asks for confirmation
+// and remembers the answer
+func (r *Runner) approve(block Block, blockNum, totalBlocks int) bool {
+	blockHash := HashBlockWithGlobals(block, r.globals)
+	if r.opts.Approvals != nil && r.opts.Approvals.IsApproved(blockHash) {
+		r.emit(Event{Type: EventApproval, Block: blockNum, Name: block.Name, Decision: DecisionRemembered})
+		return true
+	}
+
+	confirm := r.opts.Confirm
+	if confirm == nil {
+		confirm = r.promptForBlock
+	}
+	if !confirm(block, blockNum, totalBlocks) {
+		r.emit(Event{Type: EventApproval, Block: blockNum, Name: block.Name, Decision: DecisionDeclined})
+		return false
+	}
+	r.emit(Event{Type: EventApproval, Block: blockNum, Name: block.Name, Decision: DecisionApproved})
+
+	if r.opts.Approvals != nil {
+		//don't fail the run if we can't remember the approval.
+		_ = r.opts.Approvals.Approve(blockHash)
+	}
+	return true
+}
+
+// promptForBlock prompts the user for confirmation before executing a block
+// Returns true if user confirms with "y", false otherwise
+func (r *Runner) promptForBlock(block Block, blockNum, totalBlocks int) bool {
+	fmt.Fprintf(r.stdout, "\n--- Block %d of %d ---\n", blockNum, totalBlocks)
+	if block.Name != "" {
+		fmt.Fprintf(r.stdout, "Block Name: %s\n", block.Name)
+	} else {
+		// Show first command as identifier if no name
+		if len(block.Commands) > 0 {
+			firstCmd := block.Commands[0]
+			if len(firstCmd) > 50 {
+				firstCmd = firstCmd[:50] + "..."
+			}
+			fmt.Fprintf(r.stdout, "Command: %s\n", firstCmd)
+		}
+	}
+
+	// Show commands that will be executed
+	if len(block.Commands) > 0 {
+		fmt.Fprintln(r.stdout, "Commands to execute:")
+		for i, cmd := range block.Commands {
+			fmt.Fprintf(r.stdout, "  %d. %s\n", i+1, cmd)
+		}
+	}
+
+	// Show which program runs the commands when it isn't sh, e.g. for a fenced python block
+	if interpreter := describeInterpreter(block); interpreter != "" {
+		fmt.Fprintf(r.stdout, "Interpreter: %s\n", interpreter)
+	}
+
+	// Show variables if any
+	if len(block.Variables) > 0 {
+		fmt.Fprintln(r.stdout, "Variables:")
+		for varName, varValue := range block.Variables {
+			if strings.HasPrefix(varValue, "#PROMPT:") {
+				fmt.Fprintf(r.stdout, "  %s = #prompt(\"%s\")\n", varName, strings.TrimPrefix(varValue, "#PROMPT:"))
+			} else {
+				fmt.Fprintf(r.stdout, "  %s = \"%s\"\n", varName, varValue)
+			}
+		}
+	}
+
+	// Show the attributes, which change how and whether the block runs, e.g. an unless command that
+	// runs right after approval
+	if len(block.Attributes) > 0 {
+		var names []string
+		for name := range block.Attributes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Fprintln(r.stdout, "Attributes:")
+		for _, name := range names {
+			fmt.Fprintf(r.stdout, "  %s = \"%s\"\n", name, block.Attributes[name])
+		}
+	}
+
+	// A background block keeps running its readiness checks, a ready-cmd among them, until it is ready
+	if boolAttribute(block, "background", false) {
+		fmt.Fprintf(r.stdout, "Background: yes, waits for %s\n", r.readinessSummary(block, r.globals))
+	}
+
+	// Show the global variables the block uses, whose values are part of what gets approved
+	if used := usedGlobals(block, r.globals); len(used) > 0 {
+		var names []string
+		for varName := range used {
+			names = append(names, varName)
+		}
+		sort.Strings(names)
+		fmt.Fprintln(r.stdout, "Global variables:")
+		for _, varName := range names {
+			fmt.Fprintf(r.stdout, "  %s = \"%s\"\n", varName, used[varName])
+		}
+	}
+
+	// Prompt for confirmation
+	fmt.Fprint(r.stdout, "\nExecute this block? (y/n): ")
+	input, err := r.readLine()
+	if err != nil {
+		fmt.Fprintf(r.stdout, "\nError reading input: %v\n", err)
+		return false
+	}
+
+	fmt.Fprintln(r.stdout) //ensure next prompt appears on new line
+
+	response := strings.TrimSpace(strings.ToLower(input))
+	return response == "y" || response == "yes"
+}
+
+// askPrompts asks the user for the value of every prompt variable and stores the answers in place
+func (r *Runner) askPrompts(variables map[string]string) error {
+	for varName, varValue := range variables {
+		if strings.HasPrefix(varValue, "#PROMPT:") {
+			question := strings.TrimPrefix(varValue, "#PROMPT:")
+			// Ensure prompt appears on a new line
+			fmt.Fprintf(r.stdout, "\n%s ", question)
+			input, err := r.readLine()
+			var interruptedErr *InterruptedError
+			if errors.As(err, &interruptedErr) {
+				return err
+			}
+			if err != nil {
+				return fmt.Errorf("error reading input for prompt: %v", err)
+			}
+			variables[varName] = strings.TrimSpace(input)
+		}
+	}
+	return nil
+}
+
+// lineRead is the outcome of reading a line of input
+type lineRead struct {
+	line string
+	err  error
+}
+
+// readLine reads a line of input for a prompt or confirmation. A read can't be cancelled, so it
+// happens in the background and an interruption of the run only stops waiting for it; the line
+// is then returned by the next call.
+func (r *Runner) readLine() (string, error) {
+	r.stopMu.Lock()
+	stop := r.stopRead
+	r.stopMu.Unlock()
+
+	if r.line == nil {
+		r.line = make(chan lineRead, 1)
+		go func(line chan<- lineRead) {
+			input, err := r.input.ReadString('\n')
+			line <- lineRead{line: input, err: err}
+		}(r.line)
+	}
+
+	select {
+	case read := <-r.line:
+		r.line = nil
+		return read.line, read.err
+	case <-stop:
+		return "", &InterruptedError{Signal: r.interruption()}
+	}
+}
+
+// commandInput returns the stdin of a block's commands. A read of a question that was interrupted
+// still waits for the next line of input, so rather than compete with it for that line the commands
+// get no input at all.
+func (r *Runner) commandInput(messages io.Writer) io.Reader {
+	if r.line != nil {
+		fmt.Fprintln(messages, "\nInput is still read for an interrupted question, so the commands of this block get no input")
+		return nil
+	}
+	return r.stdin
+}
+
+// blockIO holds the streams of a running block: its commands' input and output and the runner's messages about it
+type blockIO struct {
+	stdin    io.Reader
+	stdout   io.Writer
+	stderr   io.Writer
+	index    int    // 1-based block number
+	label    string // names the block in its output
+	labelled bool   // every line of output is already labelled with the block
+}
+
+// runBlock executes a single RR block whose prompts have been answered in blockVars.
+// runVars holds the global and captured variables of the run; captures made by this block are added to it.
+// Background blocks are started and only waited for until they are ready.
+func (r *Runner) runBlock(ctx context.Context, block Block, blockVars, runVars map[string]string, out blockIO) error {
+	r.emit(Event{Type: EventBlockStart, Block: out.index, Name: block.Name})
+	if boolAttribute(block, "background", false) {
+		return r.startBackground(ctx, block, blockVars, runVars, out)
+	}
+
+	blockTimeout := durationAttribute(block, "timeout", r.opts.Timeout)
+	commandTimeout := durationAttribute(block, "command-timeout", r.opts.CommandTimeout)
+	retries := intAttribute(block, "retries", r.opts.Retries)
+	backoff := r.opts.Backoff
+	if backoff <= 0 {
+		backoff = defaultBackoff
+	}
+	backoff = durationAttribute(block, "backoff", backoff)
+	blockCtx, cancel := withTimeout(ctx, blockTimeout)
+	defer cancel()
+
+	// commandError describes a failed command, naming the limit that stopped it if it timed out
+	commandError := func(command string, err error) error {
+		if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+			limit := commandTimeout
+			if blockCtx.Err() != nil {
+				limit = blockTimeout
+			}
+			err = &TimeoutError{Limit: limit}
+			fmt.Fprintf(out.stdout, "\nTimed out after %s: %s\n", limit, command)
+		}
+		return &CommandError{Command: command, ExitCode: exitStatus(err), Err: err}
+	}
+
+	// Blocks in other languages run as a script, started from the shell like any other command
+	interpreter, isScript := blockInterpreter(block)
+	if err := checkInterpreter(interpreter); err != nil {
+		return err
+	}
+	shell := interpreter
+	if isScript {
+		shell = "sh"
+	}
+
+	// All commands of a block share one shell so cd, export and friends carry over
+	blockVariables := mergeVariables(r.opts.EnvVars, runVars, blockVars)
+	env := commandEnvironment(block, r.opts, blockVariables)
+	dir, err := blockDir(block, r.opts, blockVariables)
+	if err != nil {
+		return err
+	}
+
+	// Output that is compared with the expected output is captured while it's streamed
+	expect, expects := blockExpectation(block)
+	var output bytes.Buffer
+	stdout := out.stdout
+	if expect.hasOutput {
+		stdout = io.MultiWriter(out.stdout, &output)
+	}
+
+	stdout, stderr := r.eventOutput(out.index, stdout, out.stderr)
+	session, err := startShellSession(shell, dir, out.stdin, stdout, stderr, env)
+	if err != nil {
+		return fmt.Errorf("error starting shell: %v", err)
+	}
+	defer session.close()
+	defer r.trackSession(session)()
+
+	// Execute each command
+	exitCode := 0
+	for _, cmd := range block.Commands {
+		// Variable precedence, lowest first: .env, global and captured variables, block variables
+		variables := mergeVariables(r.opts.EnvVars, runVars, blockVars)
+
+		if varName, captureCmd, isCapture := ParseCapture(cmd); isCapture && !isScript {
+			captureCmd = substituteVariables(captureCmd, variables)
+			fmt.Fprintf(out.stdout, "\nCapturing #%s from: %s\n", varName, captureCmd)
+
+			var value string
+			err := r.retry(blockCtx, out.stdout, retries, backoff, captureCmd, func() error {
+				cmdCtx, cancelCmd := withTimeout(blockCtx, commandTimeout)
+				defer cancelCmd()
+				end := r.startCommand(out.index, captureCmd)
+				var err error
+				value, err = session.capture(cmdCtx, captureCmd)
+				end(err)
+				r.noticeTerminalInterrupt(session)
+				return err
+			})
+			if err != nil {
+				return commandError(captureCmd, err)
+			}
+
+			// The captured value replaces any block variable of the same name for the rest of the block
+			runVars[varName] = value
+			if _, exists := blockVars[varName]; exists {
+				blockVars[varName] = value
+			}
+
+			// Keep the session's environment in step with the variables when they are exported
+			if boolAttribute(block, "export-vars", r.opts.ExportVars) && isEnvName(varName) {
+				exportCmd := "export " + varName + "=" + shellQuote(value)
+				if err := session.run(blockCtx, exportCmd); err != nil {
+					return commandError(exportCmd, err)
+				}
+			}
+			continue
+		}
+
+		cmd = substituteVariables(cmd, variables)
+		label := cmd // how the command is named in messages and errors
+		if isScript {
+			script := cmd
+			scriptCmd, remove, err := writeScript(interpreter, script)
+			if err != nil {
+				return fmt.Errorf("error writing script: %v", err)
+			}
+			defer remove()
+			cmd = scriptCmd
+			label = interpreter + " script"
+			fmt.Fprintf(out.stdout, "\n%s:\n%s\n", label, script)
+		}
+
+		// Display block name or command for confirmation
+		if block.Name != "" && !out.labelled {
+			fmt.Fprintf(out.stdout, "\n[%s]\nExecuting: %s\nOutput:\n", block.Name, label)
+		} else {
+			fmt.Fprintf(out.stdout, "\nExecuting: %s\nOutput:\n", label)
+		}
+
+		// Execute the command. Exiting with the expected exit code ends the block like a failure
+		// would, but isn't one.
+		exited := false
+		err := r.retry(blockCtx, out.stdout, retries, backoff, label, func() error {
+			cmdCtx, cancelCmd := withTimeout(blockCtx, commandTimeout)
+			defer cancelCmd()
+			end := r.startCommand(out.index, label)
+			err := session.run(cmdCtx, cmd)
+			end(err)
+			r.noticeTerminalInterrupt(session)
+			if err != nil && expect.exitCode != 0 && exitStatus(err) == expect.exitCode {
+				exited = true
+				return nil
+			}
+			return err
+		})
+		if err != nil {
+			return commandError(label, err)
+		}
+		if exited {
+			exitCode = expect.exitCode
+			break
+		}
+	}
+
+	if expects {
+		if exitCode != expect.exitCode {
+			return &ExitCodeMismatchError{Expected: expect.exitCode, Actual: exitCode}
+		}
+		if err := expect.checkOutput(output.String()); err != nil {
+			var mismatch *OutputMismatchError
+			if errors.As(err, &mismatch) {
+				fmt.Fprintf(out.stdout, "\nOutput doesn't match what the block expects:\n%s", unifiedDiff(mismatch.Expected, mismatch.Actual))
+			}
+			return err
+		}
+	}
+
+	if err := session.close(); err != nil {
+		return fmt.Errorf("shell exited with error: %v", err)
+	}
+
+	return nil
+}
+
+// retry makes an attempt at running a command and repeats it up to retries times while it fails,
+// waiting backoff before the first retry and doubling the wait before every further one
+func (r *Runner) retry(ctx context.Context, out io.Writer, retries int, backoff time.Duration, command string, attempt func() error) error {
+	err := attempt()
+	delay := backoff
+	for n := 1; n <= retries && err != nil; n++ {
+		var statusErr *exitStatusError
+		if !errors.As(err, &statusErr) {
+			// The shell is gone after a timeout or an exit, so there is nothing left to retry in
+			return err
+		}
+		if r.interruption() != nil {
+			return err
+		}
+
+		fmt.Fprintf(out, "\nAttempt %d of %d failed with exit code %d, retrying in %s: %s\n",
+			n, retries+1, statusErr.code, delay, command)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+
+		fmt.Fprintf(out, "Attempt %d of %d: %s\n", n+1, retries+1, command)
+		err = attempt()
+		delay = min(delay*2, maxBackoff)
+	}
+	if err != nil && retries > 0 {
+		fmt.Fprintf(out, "\nGiving up after %d attempts: %s\n", retries+1, command)
+	}
+	return err
+}
+
+// withTimeout derives a context that expires after timeout, or never if timeout is zero
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
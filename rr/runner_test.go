@@ -0,0 +1,711 @@
+package rr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRunBlock_CaptureIsSharedWithLaterBlocks(t *testing.T) {
+	runner := NewRunner(Options{Stdout: &bytes.Buffer{}})
+	runVars := map[string]string{"greeting": "hello"}
+
+	first := Block{
+		Variables: map[string]string{},
+		Commands:  []string{"captured = #capture(echo #greeting world)"},
+	}
+	if err := runner.runBlock(context.Background(), first, first.Variables, runVars, blockIO{stdout: runner.stdout, stderr: runner.stderr}); err != nil {
+		t.Fatalf("Expected capture block to succeed, got %v", err)
+	}
+	if runVars["captured"] != "hello world" {
+		t.Fatalf("Expected captured value 'hello world', got '%s'", runVars["captured"])
+	}
+
+	second := Block{
+		Variables: map[string]string{},
+		Commands:  []string{`test "#captured" = "hello world"`},
+	}
+	if err := runner.runBlock(context.Background(), second, second.Variables, runVars, blockIO{stdout: runner.stdout, stderr: runner.stderr}); err != nil {
+		t.Errorf("Expected later block to see the captured value, got %v", err)
+	}
+}
+
+func TestRunBlock_ExportVars(t *testing.T) {
+	block := Block{
+		Variables: map[string]string{"BLOCK_VAR": "block"},
+		Commands: []string{
+			`test "$ENV_FILE_VAR" = "from-env-file"`,
+			`test "$BLOCK_VAR" = "block"`,
+			"CAPTURED = #capture(echo captured)",
+			`test "$CAPTURED" = "captured"`,
+		},
+	}
+	runner := NewRunner(Options{
+		Stdout:     &bytes.Buffer{},
+		EnvVars:    map[string]string{"ENV_FILE_VAR": "from-env-file"},
+		ExportVars: true,
+	})
+
+	if err := runner.runBlock(context.Background(), block, block.Variables, map[string]string{}, blockIO{stdout: runner.stdout, stderr: runner.stderr}); err != nil {
+		t.Errorf("Expected variables to be exported to commands, got %v", err)
+	}
+}
+
+func TestRun_UsesInjectedStreams(t *testing.T) {
+	doc := Parse("<!-- RR[Greet]\nname = #prompt(\"Name?\")\necho \"hello #name\"\n-->")
+
+	var stdout bytes.Buffer
+	runner := NewRunner(Options{
+		Stdin:  strings.NewReader("world\n"),
+		Stdout: &stdout,
+		Stderr: &bytes.Buffer{},
+		Trust:  true,
+	})
+
+	if err := runner.Run(context.Background(), doc); err != nil {
+		t.Fatalf("Expected run to succeed, got %v", err)
+	}
+	if !strings.Contains(stdout.String(), "hello world\n") {
+		t.Errorf("Expected command output on the injected stdout, got %q", stdout.String())
+	}
+}
+
+func TestRun_ConfirmAndApprovals(t *testing.T) {
+	doc := Parse("<!-- RR[First]\ntrue\n-->\n<!-- RR[Second]\ntrue\n-->")
+	store := NewApprovalStore(t.TempDir())
+
+	var asked []string
+	runner := NewRunner(Options{
+		Stdout:    &bytes.Buffer{},
+		Approvals: store,
+		Confirm: func(block Block, blockNum, totalBlocks int) bool {
+			asked = append(asked, block.Name)
+			return block.Name == "First"
+		},
+	})
+
+	if err := runner.Run(context.Background(), doc); err != nil {
+		t.Fatalf("Expected run to succeed, got %v", err)
+	}
+	if len(asked) != 2 {
+		t.Fatalf("Expected both blocks to be confirmed, got %v", asked)
+	}
+	if !store.IsApproved(HashBlock(doc.Blocks[0])) || store.IsApproved(HashBlock(doc.Blocks[1])) {
+		t.Error("Expected only the confirmed block to be remembered")
+	}
+
+	asked = nil
+	if err := runner.Run(context.Background(), doc); err != nil {
+		t.Fatalf("Expected second run to succeed, got %v", err)
+	}
+	if len(asked) != 1 || asked[0] != "Second" {
+		t.Errorf("Expected only the unapproved block to be confirmed again, got %v", asked)
+	}
+}
+
+func TestRun_ChangedGlobalNeedsApproval(t *testing.T) {
+	doc := Parse("<!-- RR-VARS\ntarget = \"hello\"\n-->\n<!-- RR[Echo]\necho \"target is #target\"\n-->")
+	store := NewApprovalStore(t.TempDir())
+
+	asked := 0
+	runner := NewRunner(Options{
+		Stdout:    &bytes.Buffer{},
+		Approvals: store,
+		Confirm: func(block Block, blockNum, totalBlocks int) bool {
+			asked++
+			return true
+		},
+	})
+	for i := 0; i < 2; i++ {
+		if err := runner.Run(context.Background(), doc); err != nil {
+			t.Fatalf("Expected run to succeed, got %v", err)
+		}
+	}
+	if asked != 1 {
+		t.Fatalf("Expected an approved block with unchanged globals not to be confirmed again, got %d confirmations", asked)
+	}
+
+	doc.Globals["target"] = "rm -rf SOMETHING"
+	if err := runner.Run(context.Background(), doc); err != nil {
+		t.Fatalf("Expected second run to succeed, got %v", err)
+	}
+	if asked != 2 {
+		t.Errorf("Expected the block to be confirmed again after its global changed, got %d confirmations", asked)
+	}
+}
+
+func TestPromptForBlock_ShowsGlobals(t *testing.T) {
+	doc := Parse("<!-- RR-VARS\ntarget = \"hello\"\nunused = \"x\"\n-->\n<!-- RR[Echo]\necho \"target is #target\"\n-->")
+
+	var stdout bytes.Buffer
+	runner := NewRunner(Options{Stdin: strings.NewReader("n\n"), Stdout: &stdout})
+	runner.Run(context.Background(), doc)
+
+	if !strings.Contains(stdout.String(), "Global variables:\n  target = \"hello\"\n") {
+		t.Errorf("Expected the used global to be shown, got %q", stdout.String())
+	}
+	if strings.Contains(stdout.String(), "unused") {
+		t.Errorf("Expected unused globals not to be shown, got %q", stdout.String())
+	}
+}
+
+func TestPromptForBlock_ShowsAttributes(t *testing.T) {
+	doc := Parse("<!-- RR[Guarded]{unless=\"echo HIDDEN >&2; false\" cwd=web}\necho body\n-->")
+
+	var stdout bytes.Buffer
+	runner := NewRunner(Options{Stdin: strings.NewReader("n\n"), Stdout: &stdout})
+	runner.Run(context.Background(), doc)
+
+	if !strings.Contains(stdout.String(), "Attributes:\n  cwd = \"web\"\n  unless = \"echo HIDDEN >&2; false\"\n") {
+		t.Errorf("Expected the attributes to be shown, got %q", stdout.String())
+	}
+}
+
+func TestPromptForBlock_ShowsInterpreter(t *testing.T) {
+	doc := Parse("```python rr\nprint(1)\n```")
+
+	var stdout bytes.Buffer
+	runner := NewRunner(Options{Stdin: strings.NewReader("n\n"), Stdout: &stdout})
+	runner.Run(context.Background(), doc)
+
+	if !strings.Contains(stdout.String(), "Interpreter: python3, runs as a script\n") {
+		t.Errorf("Expected the interpreter to be shown, got %q", stdout.String())
+	}
+}
+
+func TestPromptForBlock_ShowsReadinessChecks(t *testing.T) {
+	doc := Parse("<!-- RR-VARS\nport = \"8080\"\n-->\n<!-- RR[Server]{background ready-cmd=\"curl -f localhost:#port\"}\n./server\n-->")
+
+	var stdout bytes.Buffer
+	runner := NewRunner(Options{Stdin: strings.NewReader("n\n"), Stdout: &stdout})
+	runner.Run(context.Background(), doc)
+
+	if !strings.Contains(stdout.String(), "Background: yes, waits for command curl -f localhost:8080\n") {
+		t.Errorf("Expected the readiness command to be shown, got %q", stdout.String())
+	}
+}
+
+func TestRun_ContextCancelled(t *testing.T) {
+	doc := Parse("<!-- RR[Slow]\nsleep 5\n-->\n<!-- RR[Never]\ntouch never\n-->")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	runner := NewRunner(Options{Stdout: &bytes.Buffer{}, Stderr: &bytes.Buffer{}, Trust: true})
+	start := time.Now()
+	err := runner.Run(ctx, doc)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the context's error, got %v", err)
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Error("Expected the running command to be stopped when the context is cancelled")
+	}
+}
+
+// resultStatuses lists the status of every result of the runner's last run
+func resultStatuses(runner *Runner) []BlockStatus {
+	var statuses []BlockStatus
+	for _, result := range runner.Results() {
+		statuses = append(statuses, result.Status)
+	}
+	return statuses
+}
+
+func TestRun_AbortsOnFailureByDefault(t *testing.T) {
+	doc := Parse("<!-- RR[Breaks]\nfalse\n-->\n<!-- RR[After]\ntrue\n-->")
+
+	var stdout bytes.Buffer
+	runner := NewRunner(Options{Stdout: &stdout, Trust: true})
+	err := runner.Run(context.Background(), doc)
+
+	var blockErr *BlockFailedError
+	if !errors.As(err, &blockErr) || blockErr.Index != 1 {
+		t.Fatalf("Expected block 1 to fail, got %v", err)
+	}
+	statuses := resultStatuses(runner)
+	if len(statuses) != 2 || statuses[0] != StatusFailed || statuses[1] != StatusSkipped {
+		t.Errorf("Expected [failed skipped], got %v", statuses)
+	}
+	if !strings.Contains(stdout.String(), "2 block(s): 0 succeeded, 1 failed, 1 skipped") {
+		t.Errorf("Expected summary in output, got %q", stdout.String())
+	}
+}
+
+func TestRun_KeepGoing(t *testing.T) {
+	doc := Parse("<!-- RR[First]\nfalse\n-->\n<!-- RR[Second]\ntrue\n-->\n<!-- RR[Third]\nexit 3\n-->")
+
+	runner := NewRunner(Options{Stdout: &bytes.Buffer{}, Trust: true, KeepGoing: true})
+	err := runner.Run(context.Background(), doc)
+
+	var runErr *RunFailedError
+	if !errors.As(err, &runErr) {
+		t.Fatalf("Expected RunFailedError, got %v", err)
+	}
+	if len(runErr.Failures) != 2 || runErr.Failures[0].Index != 1 || runErr.Failures[1].Index != 3 {
+		t.Errorf("Expected blocks 1 and 3 to fail, got %v", runErr)
+	}
+	if runErr.Error() != "2 blocks failed: 1 (First), 3 (Third)" {
+		t.Errorf("Unexpected error message: %s", runErr.Error())
+	}
+	var blockErr *BlockFailedError
+	if !errors.As(err, &blockErr) {
+		t.Error("Expected the failed blocks to be reachable with errors.As")
+	}
+	statuses := resultStatuses(runner)
+	if len(statuses) != 3 || statuses[0] != StatusFailed || statuses[1] != StatusSucceeded || statuses[2] != StatusFailed {
+		t.Errorf("Expected [failed succeeded failed], got %v", statuses)
+	}
+}
+
+func TestRun_OnErrorAttributeOverridesKeepGoing(t *testing.T) {
+	doc := Parse("<!-- RR[Optional]{on-error=continue}\nfalse\n-->\n" +
+		"<!-- RR[Required]{on-error=abort}\nfalse\n-->\n" +
+		"<!-- RR[After]\ntrue\n-->")
+
+	runner := NewRunner(Options{Stdout: &bytes.Buffer{}, Trust: true})
+	err := runner.Run(context.Background(), doc)
+
+	var runErr *RunFailedError
+	if !errors.As(err, &runErr) || len(runErr.Failures) != 2 {
+		t.Fatalf("Expected two failed blocks, got %v", err)
+	}
+	statuses := resultStatuses(runner)
+	if len(statuses) != 3 || statuses[2] != StatusSkipped {
+		t.Errorf("Expected the run to abort after the second block, got %v", statuses)
+	}
+
+	runner = NewRunner(Options{Stdout: &bytes.Buffer{}, Trust: true, KeepGoing: true})
+	runner.Run(context.Background(), doc)
+	if statuses := resultStatuses(runner); statuses[2] != StatusSkipped {
+		t.Errorf("Expected on-error=abort to override KeepGoing, got %v", statuses)
+	}
+}
+
+func TestRun_OnErrorPrompt(t *testing.T) {
+	doc := Parse("<!-- RR[Flaky]{on-error=prompt}\nfalse\n-->\n<!-- RR[After]\ntrue\n-->")
+
+	for answer, expected := range map[string]BlockStatus{"y\n": StatusSucceeded, "n\n": StatusSkipped} {
+		// A file rather than a reader, so the shell doesn't consume the answer through a copying pipe
+		answerPath := filepath.Join(t.TempDir(), "answer")
+		os.WriteFile(answerPath, []byte(answer), 0644)
+		stdin, err := os.Open(answerPath)
+		if err != nil {
+			t.Fatalf("Failed to open answer file: %v", err)
+		}
+		defer stdin.Close()
+
+		var stdout bytes.Buffer
+		runner := NewRunner(Options{Stdin: stdin, Stdout: &stdout, Trust: true})
+		runner.Run(context.Background(), doc)
+
+		if !strings.Contains(stdout.String(), "Continue with the remaining blocks? (y/n)") {
+			t.Errorf("Expected to be asked whether to continue, got %q", stdout.String())
+		}
+		if statuses := resultStatuses(runner); len(statuses) != 2 || statuses[1] != expected {
+			t.Errorf("Answer %q: expected second block to be %s, got %v", answer, expected, statuses)
+		}
+	}
+}
+
+func TestRun_DeclinedBlocksAreSkipped(t *testing.T) {
+	doc := Parse("<!-- RR[Declined]\ntrue\n-->\n<!-- RR[Filtered]\ntrue\n-->")
+	filter, _ := NewFilter(nil, []string{"Declined"}, nil, nil)
+
+	runner := NewRunner(Options{
+		Stdout:  &bytes.Buffer{},
+		Filter:  filter,
+		Confirm: func(Block, int, int) bool { return false },
+	})
+	if err := runner.Run(context.Background(), doc); err != nil {
+		t.Fatalf("Expected run to succeed, got %v", err)
+	}
+
+	results := runner.Results()
+	if len(results) != 1 || results[0].Name != "Declined" || results[0].Status != StatusSkipped {
+		t.Errorf("Expected only the declined block to be reported as skipped, got %+v", results)
+	}
+}
+
+func TestRun_CommandTimeout(t *testing.T) {
+	doc := Parse("<!-- RR[Hangs]{command-timeout=200ms}\ntrue\nsleep 5\necho never\n-->\n<!-- RR[After]\ntrue\n-->")
+
+	var stdout bytes.Buffer
+	runner := NewRunner(Options{Stdout: &stdout, Trust: true, KeepGoing: true})
+	start := time.Now()
+	err := runner.Run(context.Background(), doc)
+
+	var blockErr *BlockFailedError
+	if !errors.As(err, &blockErr) {
+		t.Fatalf("Expected BlockFailedError, got %v", err)
+	}
+	if blockErr.Command != "sleep 5" {
+		t.Errorf("Expected the timed out command to be reported, got '%s'", blockErr.Command)
+	}
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) || timeoutErr.Limit != 200*time.Millisecond {
+		t.Errorf("Expected TimeoutError with the command timeout, got %v", err)
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Error("Expected the command to be killed when it timed out")
+	}
+	if !strings.Contains(stdout.String(), "Timed out after 200ms: sleep 5") {
+		t.Errorf("Expected the timeout to be reported, got %q", stdout.String())
+	}
+	if statuses := resultStatuses(runner); len(statuses) != 2 || statuses[1] != StatusSucceeded {
+		t.Errorf("Expected the timeout to count as a block failure only, got %v", statuses)
+	}
+}
+
+func TestRun_BlockTimeout(t *testing.T) {
+	doc := Parse("<!-- RR[Slow]\nsleep 0.1\nsleep 0.1\nsleep 5\n-->")
+
+	runner := NewRunner(Options{Stdout: &bytes.Buffer{}, Trust: true, Timeout: 300 * time.Millisecond, CommandTimeout: time.Minute})
+	err := runner.Run(context.Background(), doc)
+
+	var timeoutErr *TimeoutError
+	if !errors.As(err, &timeoutErr) || timeoutErr.Limit != 300*time.Millisecond {
+		t.Fatalf("Expected TimeoutError with the block timeout, got %v", err)
+	}
+	var blockErr *BlockFailedError
+	if errors.As(err, &blockErr) && blockErr.Command != "sleep 5" {
+		t.Errorf("Expected the command running at expiry to be reported, got '%s'", blockErr.Command)
+	}
+}
+
+func TestRun_RetriesUntilCommandSucceeds(t *testing.T) {
+	counter := filepath.Join(t.TempDir(), "attempts")
+	doc := Parse("<!-- RR[Flaky]{retries=3 backoff=10ms}\n" +
+		"n=$(cat " + counter + " 2>/dev/null || echo 0); n=$((n+1)); echo $n > " + counter + "; test $n -ge 3\n-->")
+
+	var stdout bytes.Buffer
+	runner := NewRunner(Options{Stdout: &stdout, Trust: true})
+	if err := runner.Run(context.Background(), doc); err != nil {
+		t.Fatalf("Expected the block to succeed on the third attempt, got %v", err)
+	}
+
+	output := stdout.String()
+	for _, expected := range []string{
+		"Attempt 1 of 4 failed with exit code 1, retrying in 10ms",
+		"Attempt 2 of 4 failed with exit code 1, retrying in 20ms",
+		"Attempt 3 of 4:",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected output to contain %q, got %q", expected, output)
+		}
+	}
+	if strings.Contains(output, "Attempt 4 of 4") {
+		t.Error("Expected no further attempts after the command succeeded")
+	}
+}
+
+func TestRun_RetriesExhausted(t *testing.T) {
+	doc := Parse("<!-- RR[Broken]\nexit_code() { return 4; }; exit_code\n-->")
+
+	var stdout bytes.Buffer
+	runner := NewRunner(Options{Stdout: &stdout, Trust: true, Retries: 2, Backoff: 10 * time.Millisecond})
+	err := runner.Run(context.Background(), doc)
+
+	var blockErr *BlockFailedError
+	if !errors.As(err, &blockErr) || blockErr.ExitCode != 4 {
+		t.Fatalf("Expected the block to fail with exit code 4, got %v", err)
+	}
+	if !strings.Contains(stdout.String(), "Giving up after 3 attempts") {
+		t.Errorf("Expected the final attempt to be reported, got %q", stdout.String())
+	}
+}
+
+func TestRun_RetriesAttributeOverridesOption(t *testing.T) {
+	doc := Parse("<!-- RR[NoRetry]{retries=0}\nfalse\n-->")
+
+	var stdout bytes.Buffer
+	runner := NewRunner(Options{Stdout: &stdout, Trust: true, Retries: 5, Backoff: time.Hour})
+	if err := runner.Run(context.Background(), doc); err == nil {
+		t.Fatal("Expected the block to fail")
+	}
+	if strings.Contains(stdout.String(), "retrying") {
+		t.Errorf("Expected retries=0 to disable retries, got %q", stdout.String())
+	}
+}
+
+func TestRun_SkipsBlocksWhoseNeedsFailed(t *testing.T) {
+	doc := Parse("<!-- RR[Database]\nfalse\n-->\n" +
+		"<!-- RR[Migrate]{needs=Database}\ntrue\n-->\n" +
+		"<!-- RR[Lint]\ntrue\n-->")
+
+	var stdout bytes.Buffer
+	runner := NewRunner(Options{Stdout: &stdout, Trust: true, KeepGoing: true})
+	runner.Run(context.Background(), doc)
+
+	statuses := resultStatuses(runner)
+	if len(statuses) != 3 || statuses[0] != StatusFailed || statuses[1] != StatusSkipped || statuses[2] != StatusSucceeded {
+		t.Errorf("Expected [failed skipped succeeded], got %v", statuses)
+	}
+	if !strings.Contains(stdout.String(), "Skipping block 2: it needs Database, which did not succeed") {
+		t.Errorf("Expected the skipped dependency to be reported, got %q", stdout.String())
+	}
+}
+
+func TestRun_CleanupRunsAfterFailure(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "cleaned")
+	doc := Parse("<!-- RR[Setup]\nfalse\n-->\n" +
+		"<!-- RR[Cleanup]{always needs=Setup}\ntouch " + marker + "\n-->\n" +
+		"<!-- RR[Test]\ntrue\n-->")
+
+	runner := NewRunner(Options{Stdout: &bytes.Buffer{}, Trust: true})
+	err := runner.Run(context.Background(), doc)
+
+	var blockErr *BlockFailedError
+	if !errors.As(err, &blockErr) || blockErr.Index != 1 {
+		t.Fatalf("Expected the setup failure to be returned, got %v", err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Error("Expected the cleanup block to run after the failure")
+	}
+	results := runner.Results()
+	if len(results) != 3 || results[1].Name != "Test" || results[1].Status != StatusSkipped ||
+		results[2].Name != "Cleanup" || results[2].Status != StatusSucceeded {
+		t.Errorf("Expected Test to be skipped and Cleanup to succeed, got %+v", results)
+	}
+}
+
+func TestRun_CleanupRunsAfterCancel(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "cleaned")
+	doc := Parse("<!-- RR[Slow]\nsleep 5\n-->\n" +
+		"<!-- RR[Never]\ntrue\n-->\n" +
+		"<!-- RR[Cleanup]{always}\nsleep 0.2\ntouch " + marker + "\n-->")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	runner := NewRunner(Options{Stdout: &bytes.Buffer{}, Trust: true})
+	err := runner.Run(ctx, doc)
+
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the context's error, got %v", err)
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Error("Expected the cleanup block to run to completion after the run was cancelled")
+	}
+	statuses := resultStatuses(runner)
+	if len(statuses) != 3 || statuses[0] != StatusCancelled || statuses[1] != StatusSkipped || statuses[2] != StatusSucceeded {
+		t.Errorf("Expected [cancelled skipped succeeded], got %v", statuses)
+	}
+}
+
+func TestRun_InterruptStopsRunningCommands(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "cleaned")
+	doc := Parse("<!-- RR[Slow]\nsleep 5\n-->\n" +
+		"<!-- RR[Next]\ntrue\n-->\n" +
+		"<!-- RR[Cleanup]{always}\ntouch " + marker + "\n-->")
+
+	var stdout bytes.Buffer
+	runner := NewRunner(Options{Stdout: &stdout, Trust: true, Retries: 3})
+	time.AfterFunc(200*time.Millisecond, func() { runner.Interrupt(os.Interrupt) })
+	start := time.Now()
+	err := runner.Run(context.Background(), doc)
+
+	var interrupted *InterruptedError
+	if !errors.As(err, &interrupted) || interrupted.Signal != os.Interrupt {
+		t.Fatalf("Expected InterruptedError, got %v", err)
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Error("Expected the interrupt to be forwarded to the running command")
+	}
+	if _, err := os.Stat(marker); err != nil {
+		t.Error("Expected the cleanup block to run after the interrupt")
+	}
+	if statuses := resultStatuses(runner); len(statuses) != 3 || statuses[0] != StatusCancelled || statuses[1] != StatusSkipped || statuses[2] != StatusSucceeded {
+		t.Errorf("Expected [cancelled skipped succeeded], got %v", statuses)
+	}
+	if strings.Contains(stdout.String(), "retrying") {
+		t.Error("Expected an interrupted command not to be retried")
+	}
+}
+
+func TestRun_SecondInterruptKills(t *testing.T) {
+	// The command ignores SIGINT, so only the second interrupt stops it
+	doc := Parse("<!-- RR[Stubborn]\ntrap '' INT; sleep 5\n-->")
+
+	runner := NewRunner(Options{Stdout: &bytes.Buffer{}, Trust: true})
+	time.AfterFunc(200*time.Millisecond, func() { runner.Interrupt(os.Interrupt) })
+	time.AfterFunc(500*time.Millisecond, func() { runner.Interrupt(os.Interrupt) })
+	start := time.Now()
+	err := runner.Run(context.Background(), doc)
+
+	var interrupted *InterruptedError
+	if !errors.As(err, &interrupted) {
+		t.Fatalf("Expected InterruptedError, got %v", err)
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Error("Expected the second interrupt to kill the command")
+	}
+	if statuses := resultStatuses(runner); len(statuses) != 1 || statuses[0] != StatusCancelled {
+		t.Errorf("Expected [cancelled], got %v", statuses)
+	}
+}
+
+func TestRun_InterruptWhileWaitingForInput(t *testing.T) {
+	tests := []struct {
+		name string
+		doc  string
+		opts Options
+	}{
+		{"approval", "<!-- RR[First]\ntouch #marker\n-->", Options{}},
+		{"prompt", "<!-- RR[First]\nname = #prompt(\"Name?\")\ntouch #marker\n-->", Options{Trust: true}},
+		{"global prompt", "<!-- RR-VARS\nname = #prompt(\"Name?\")\n-->\n<!-- RR[First]\ntouch #marker\n-->", Options{Trust: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			marker := filepath.Join(t.TempDir(), "ran")
+			doc := Parse(strings.ReplaceAll(tt.doc, "#marker", marker) + "\n<!-- RR[Second]\ntouch " + marker + "\n-->")
+
+			// Nobody ever answers
+			stdin, answer := io.Pipe()
+			defer answer.Close()
+			opts := tt.opts
+			opts.Stdin = stdin
+			opts.Stdout = &bytes.Buffer{}
+			runner := NewRunner(opts)
+
+			time.AfterFunc(200*time.Millisecond, func() { runner.Interrupt(os.Interrupt) })
+			result := make(chan error, 1)
+			go func() { result <- runner.Run(context.Background(), doc) }()
+
+			select {
+			case err := <-result:
+				var interrupted *InterruptedError
+				if !errors.As(err, &interrupted) || interrupted.Signal != os.Interrupt {
+					t.Fatalf("Expected InterruptedError, got %v", err)
+				}
+			case <-time.After(3 * time.Second):
+				t.Fatal("Expected the interrupt to stop waiting for input")
+			}
+			if _, err := os.Stat(marker); err == nil {
+				t.Error("Expected no block to run after the interrupt")
+			}
+		})
+	}
+}
+
+func TestRun_CommandsGetNoInputWhileQuestionIsPending(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+	doc := Parse("<!-- RR[Ask]\nname = #prompt(\"Name?\")\necho #name\n-->\n<!-- RR[Cleanup]{always}\ncat > " + out + "\n-->")
+
+	// The question is interrupted and its read keeps waiting for a line that comes too late
+	stdin, answer := io.Pipe()
+	defer answer.Close()
+	var stdout bytes.Buffer
+	runner := NewRunner(Options{Stdin: stdin, Stdout: &stdout, Trust: true})
+
+	time.AfterFunc(200*time.Millisecond, func() { runner.Interrupt(os.Interrupt) })
+	result := make(chan error, 1)
+	go func() { result <- runner.Run(context.Background(), doc) }()
+
+	select {
+	case err := <-result:
+		var interrupted *InterruptedError
+		if !errors.As(err, &interrupted) {
+			t.Fatalf("Expected InterruptedError, got %v", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Expected the cleanup block's command not to wait for input")
+	}
+	if statuses := resultStatuses(runner); len(statuses) != 2 || statuses[1] != StatusSucceeded {
+		t.Errorf("Expected the cleanup block to succeed, got %v", statuses)
+	}
+	if !strings.Contains(stdout.String(), "the commands of this block get no input") {
+		t.Errorf("Expected a note that the commands get no input, got %q", stdout.String())
+	}
+}
+
+func TestRun_Resume(t *testing.T) {
+	tempDir := t.TempDir()
+	runs := filepath.Join(tempDir, "runs")
+	fixed := filepath.Join(tempDir, "fixed")
+	verified := filepath.Join(tempDir, "verified")
+	doc := Parse("<!-- RR[Setup]\necho ran >> " + runs + "\nTOKEN = #capture(echo token-1)\n-->\n" +
+		"<!-- RR[Migrate]\ntest -e " + fixed + "\ntest \"#TOKEN\" = token-1\n-->\n" +
+		"<!-- RR[Verify]\ntest -e " + verified + "\n-->\n" +
+		"<!-- RR[Cleanup]{always}\ntrue\n-->")
+	store := NewCheckpointStore(tempDir)
+
+	runner := NewRunner(Options{Stdout: &bytes.Buffer{}, Trust: true, Checkpoints: store})
+	if err := runner.Run(context.Background(), doc); err == nil {
+		t.Fatal("Expected the first run to fail")
+	}
+	checkpoint, _ := store.Load()
+	if len(checkpoint.Blocks) != 1 || checkpoint.Blocks[0].Name != "Setup" {
+		t.Fatalf("Expected only Setup to be recorded as completed, got %+v", checkpoint.Blocks)
+	}
+
+	os.WriteFile(fixed, nil, 0644)
+	var stdout bytes.Buffer
+	runner = NewRunner(Options{Stdout: &stdout, Trust: true, Checkpoints: store, Resume: true})
+	if err := runner.Run(context.Background(), doc); err == nil {
+		t.Fatal("Expected the resumed run to fail at Verify")
+	}
+	if !strings.Contains(stdout.String(), "Skipping block 1: it completed in the last run") {
+		t.Errorf("Expected the skipped block to be reported, got %q", stdout.String())
+	}
+	if statuses := resultStatuses(runner); len(statuses) != 4 || statuses[0] != StatusCompleted || statuses[1] != StatusSucceeded {
+		t.Errorf("Expected Migrate to succeed with the restored capture, got %v", statuses)
+	}
+	checkpoint, _ = store.Load()
+	if len(checkpoint.Blocks) != 2 || checkpoint.Blocks[0].Name != "Setup" || checkpoint.Blocks[1].Name != "Migrate" {
+		t.Fatalf("Expected Setup and Migrate to be recorded once each, got %+v", checkpoint.Blocks)
+	}
+
+	os.WriteFile(verified, nil, 0644)
+	runner = NewRunner(Options{Stdout: &bytes.Buffer{}, Trust: true, Checkpoints: store, Resume: true})
+	if err := runner.Run(context.Background(), doc); err != nil {
+		t.Fatalf("Expected the second resumed run to succeed, got %v", err)
+	}
+
+	if content, _ := os.ReadFile(runs); string(content) != "ran\n" {
+		t.Errorf("Expected the completed block not to run again, got %q", content)
+	}
+	if statuses := resultStatuses(runner); len(statuses) != 4 || statuses[0] != StatusCompleted || statuses[1] != StatusCompleted || statuses[2] != StatusSucceeded || statuses[3] != StatusSucceeded {
+		t.Errorf("Expected [completed completed succeeded succeeded], got %v", statuses)
+	}
+	if checkpoint, _ := store.Load(); len(checkpoint.Blocks) != 0 {
+		t.Errorf("Expected the state to be cleared after a successful run, got %+v", checkpoint.Blocks)
+	}
+}
+
+func TestRun_ResumeRerunsChangedBlocks(t *testing.T) {
+	tempDir := t.TempDir()
+	store := NewCheckpointStore(tempDir)
+	doc := Parse("<!-- RR[Setup]\ntrue\n-->\n<!-- RR[Broken]\nfalse\n-->")
+
+	NewRunner(Options{Stdout: &bytes.Buffer{}, Trust: true, Checkpoints: store}).Run(context.Background(), doc)
+
+	doc.Blocks[0].Commands = []string{"true", "echo changed"}
+	runner := NewRunner(Options{Stdout: &bytes.Buffer{}, Trust: true, Checkpoints: store, Resume: true})
+	runner.Run(context.Background(), doc)
+	if statuses := resultStatuses(runner); statuses[0] != StatusSucceeded {
+		t.Errorf("Expected a changed block to run again, got %v", statuses)
+	}
+}
+
+func TestRun_ResumeRerunsBlocksWithChangedGlobals(t *testing.T) {
+	tempDir := t.TempDir()
+	store := NewCheckpointStore(tempDir)
+	doc := Parse("<!-- RR-VARS\ntarget = \"hello\"\n-->\n<!-- RR[Setup]\necho #target\n-->\n<!-- RR[Broken]\nfalse\n-->")
+
+	NewRunner(Options{Stdout: &bytes.Buffer{}, Trust: true, Checkpoints: store}).Run(context.Background(), doc)
+
+	doc.Globals["target"] = "changed"
+	runner := NewRunner(Options{Stdout: &bytes.Buffer{}, Trust: true, Checkpoints: store, Resume: true})
+	runner.Run(context.Background(), doc)
+	if statuses := resultStatuses(runner); statuses[0] != StatusSucceeded {
+		t.Errorf("Expected a block whose global changed to run again, got %v", statuses)
+	}
+}
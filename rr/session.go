@@ -0,0 +1,260 @@
+package rr
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// shellSession is a single long-lived shell that runs every command of a block,
+// so state such as the working directory and exported variables carries over
+// from one command to the next.
+//
+// The shell reads its script from fd 3 and reports the exit status of each
+// command on fd 4, which leaves stdin, stdout and stderr free for the commands
+// themselves.
+type shellSession struct {
+	cmd        *exec.Cmd
+	tty        *os.File // terminal handed to the shell's process group, if any
+	script     *os.File
+	statusFile *os.File
+	status     *bufio.Reader
+	copiers    []*outputCopier   // copy output to writers that aren't files
+	watcher    *interruptWatcher // notices Ctrl-C while the shell has the terminal
+	done       bool
+}
+
+// startShellSession starts a new session of the given POSIX shell, e.g. sh or bash, in dir and wired to the given streams.
+// An empty dir is the current directory. env is the environment of the shell; nil inherits the environment of the current process.
+// The shell runs in its own process group, which is killed when a command is cancelled.
+func startShellSession(shell, dir string, stdin io.Reader, stdout, stderr io.Writer, env []string) (*shellSession, error) {
+	scriptReader, scriptWriter, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	statusReader, statusWriter, err := os.Pipe()
+	if err != nil {
+		scriptReader.Close()
+		scriptWriter.Close()
+		return nil, err
+	}
+
+	shellCmd := exec.Command(shell, "/dev/fd/3")
+	shellCmd.Dir = dir
+	shellCmd.Stdin = stdin
+	shellCmd.Env = env
+
+	// Output to writers that aren't files goes through our own pipes, see outputCopier.
+	// The copiers may share a writer, e.g. when stdout and stderr go to the same buffer.
+	var copiers []*outputCopier
+	var copyMu sync.Mutex
+	var childEnds []*os.File
+	for _, stream := range []struct {
+		fd     int
+		dst    io.Writer
+		target *io.Writer
+	}{{1, stdout, &shellCmd.Stdout}, {2, stderr, &shellCmd.Stderr}} {
+		if _, isFile := stream.dst.(*os.File); isFile || stream.dst == nil {
+			*stream.target = stream.dst
+			continue
+		}
+		copier, childEnd, err := newOutputCopier(lockedWriter{mu: &copyMu, w: stream.dst}, stream.fd)
+		if err != nil {
+			for _, c := range copiers {
+				c.close()
+			}
+			for _, f := range append(childEnds, scriptReader, scriptWriter, statusReader, statusWriter) {
+				f.Close()
+			}
+			return nil, err
+		}
+		copiers = append(copiers, copier)
+		childEnds = append(childEnds, childEnd)
+		*stream.target = childEnd
+	}
+
+	shellCmd.ExtraFiles = []*os.File{scriptReader, statusWriter}
+	// Children of a killed shell may keep its output open; don't wait for them forever
+	shellCmd.WaitDelay = time.Second
+	tty := startProcessGroup(shellCmd, stdin)
+
+	err = shellCmd.Start()
+
+	// The child holds its own copies of these ends now.
+	scriptReader.Close()
+	statusWriter.Close()
+	for _, f := range childEnds {
+		f.Close()
+	}
+
+	if err != nil {
+		scriptWriter.Close()
+		statusReader.Close()
+		for _, c := range copiers {
+			c.close()
+		}
+		return nil, err
+	}
+
+	// Without a watcher Ctrl-C still reaches the commands, the run just doesn't notice
+	var watcher *interruptWatcher
+	if tty != nil {
+		watcher, _ = startInterruptWatcher(shellCmd.Process)
+	}
+
+	return &shellSession{
+		cmd:        shellCmd,
+		tty:        tty,
+		script:     scriptWriter,
+		statusFile: statusReader,
+		status:     bufio.NewReader(statusReader),
+		copiers:    copiers,
+		watcher:    watcher,
+	}, nil
+}
+
+// run executes a single command in the session and waits for it to finish.
+// A non-zero exit status is reported as an error. When ctx is done before the command
+// finishes, the session's process group is killed and the context's error is returned.
+func (s *shellSession) run(ctx context.Context, command string) error {
+	return s.exec(ctx, command, "")
+}
+
+// capture executes a single command in the session like run, but returns its stdout
+// (without trailing newlines) instead of streaming it
+func (s *shellSession) capture(ctx context.Context, command string) (string, error) {
+	file, err := os.CreateTemp("", "rr-capture-*")
+	if err != nil {
+		return "", err
+	}
+	file.Close()
+	defer os.Remove(file.Name())
+
+	if err := s.exec(ctx, command, " >"+shellQuote(file.Name())); err != nil {
+		return "", err
+	}
+
+	output, err := os.ReadFile(file.Name())
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(output), "\r\n"), nil
+}
+
+// exec sends a command to the shell, applying the given redirections to it, and waits for its exit status
+func (s *shellSession) exec(ctx context.Context, command string, redirect string) error {
+	if s.done {
+		return errors.New("shell session has already exited")
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// The command is passed through eval so that a syntax error in it can never
+	// swallow the status report that follows. fds 3 and 4 are closed for the
+	// command so that processes it starts do not inherit the session's pipes.
+	// After the status, sync markers are printed to the output streams that are copied.
+	script := fmt.Sprintf("{ eval %s\n} 3<&- 4<&-%s\nprintf '%%d\\n' \"$?\" >&4\n", shellQuote(command), redirect)
+	for _, c := range s.copiers {
+		script += fmt.Sprintf("%s >&%d\n", syncMarkerScript, c.fd)
+	}
+	if _, err := io.WriteString(s.script, script); err != nil {
+		return s.exitError()
+	}
+
+	stop := context.AfterFunc(ctx, func() {
+		killProcessGroup(s.cmd.Process)
+	})
+	line, err := s.status.ReadString('\n')
+	if !stop() {
+		// The shell has been killed, whether or not the command got to report back
+		s.wait()
+		return ctx.Err()
+	}
+	if err != nil {
+		// The shell went away before reporting back, e.g. the command called exit.
+		return s.exitError()
+	}
+
+	for _, c := range s.copiers {
+		c.waitSync()
+	}
+
+	code, err := strconv.Atoi(strings.TrimSpace(line))
+	if err != nil {
+		return fmt.Errorf("unexpected status from shell: %q", line)
+	}
+	if code != 0 {
+		return &exitStatusError{code: code}
+	}
+
+	return nil
+}
+
+// close ends the session and waits for the shell to exit
+func (s *shellSession) close() error {
+	if s.done {
+		return nil
+	}
+	return s.wait()
+}
+
+// exitError waits for a shell that exited on its own (or was killed) and reports why
+func (s *shellSession) exitError() error {
+	if err := s.wait(); err != nil {
+		return err
+	}
+	return errors.New("shell session exited unexpectedly")
+}
+
+// wait closes the session's script so the shell exits, then reaps it
+func (s *shellSession) wait() error {
+	s.done = true
+	s.script.Close()
+	err := s.cmd.Wait()
+	s.statusFile.Close()
+	for _, c := range s.copiers {
+		c.close()
+	}
+	if s.watcher != nil {
+		s.watcher.stop()
+	}
+	restoreForeground(s.tty)
+	return err
+}
+
+// onInterrupt sets the function that is called for every Ctrl-C at the terminal while the session has it
+func (s *shellSession) onInterrupt(handler func()) {
+	if s.watcher != nil {
+		s.watcher.setHandler(handler)
+	}
+}
+
+// syncInterrupts waits until every Ctrl-C at the terminal so far has been passed to the handler
+func (s *shellSession) syncInterrupts() {
+	if s.watcher != nil {
+		s.watcher.sync()
+	}
+}
+
+// exitStatusError reports a command in the session that exited with a non-zero status
+type exitStatusError struct {
+	code int
+}
+
+func (e *exitStatusError) Error() string {
+	return fmt.Sprintf("exit status %d", e.code)
+}
+
+// shellQuote wraps a string in single quotes so the shell treats it literally
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,153 @@
+package rr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestShellSession_StatePersistsBetweenCommands(t *testing.T) {
+	tempDir := t.TempDir()
+
+	var stdout bytes.Buffer
+	session, err := startShellSession("sh", "", strings.NewReader(""), &stdout, &stdout, nil)
+	if err != nil {
+		t.Fatalf("Failed to start shell session: %v", err)
+	}
+
+	commands := []string{
+		"cd " + shellQuote(tempDir),
+		"export RR_TEST_VAR=persisted",
+		`echo "$(pwd) $RR_TEST_VAR"`,
+	}
+	for _, cmd := range commands {
+		if err := session.run(context.Background(), cmd); err != nil {
+			t.Fatalf("Command %q failed: %v", cmd, err)
+		}
+	}
+	if err := session.close(); err != nil {
+		t.Fatalf("Failed to close shell session: %v", err)
+	}
+
+	expected := tempDir + " persisted"
+	if strings.TrimSpace(stdout.String()) != expected {
+		t.Errorf("Expected output '%s', got '%s'", expected, stdout.String())
+	}
+}
+
+func TestShellSession_ReportsExitStatus(t *testing.T) {
+	session, err := startShellSession("sh", "", strings.NewReader(""), &bytes.Buffer{}, &bytes.Buffer{}, nil)
+	if err != nil {
+		t.Fatalf("Failed to start shell session: %v", err)
+	}
+	defer session.close()
+
+	err = session.run(context.Background(), "exit_code() { return 3; }; exit_code")
+	if err == nil {
+		t.Fatal("Expected error for failing command")
+	}
+	if !strings.Contains(err.Error(), "exit status 3") {
+		t.Errorf("Expected exit status 3 in error, got '%v'", err)
+	}
+
+	// The session must still be usable after a failed command
+	if err := session.run(context.Background(), "true"); err != nil {
+		t.Errorf("Expected session to survive a failed command, got %v", err)
+	}
+}
+
+func TestShellSession_QuotesAndSyntaxErrors(t *testing.T) {
+	var stdout bytes.Buffer
+	session, err := startShellSession("sh", "", strings.NewReader(""), &stdout, &bytes.Buffer{}, nil)
+	if err != nil {
+		t.Fatalf("Failed to start shell session: %v", err)
+	}
+	defer session.close()
+
+	if err := session.run(context.Background(), `echo 'single' "double"`); err != nil {
+		t.Fatalf("Expected quoted command to succeed, got %v", err)
+	}
+	if strings.TrimSpace(stdout.String()) != "single double" {
+		t.Errorf("Expected 'single double', got '%s'", stdout.String())
+	}
+
+	if err := session.run(context.Background(), `echo "unterminated`); err == nil {
+		t.Error("Expected error for command with a syntax error")
+	}
+}
+
+func TestShellSession_ExitEndsSession(t *testing.T) {
+	session, err := startShellSession("sh", "", strings.NewReader(""), &bytes.Buffer{}, &bytes.Buffer{}, nil)
+	if err != nil {
+		t.Fatalf("Failed to start shell session: %v", err)
+	}
+
+	if err := session.run(context.Background(), "exit 4"); err == nil {
+		t.Fatal("Expected error when a command exits the shell")
+	}
+	if err := session.run(context.Background(), "true"); err == nil {
+		t.Error("Expected error when running a command on an exited session")
+	}
+	if err := session.close(); err != nil {
+		t.Errorf("Expected close on exited session to succeed, got %v", err)
+	}
+}
+
+func TestShellSession_Capture(t *testing.T) {
+	var stdout bytes.Buffer
+	session, err := startShellSession("sh", "", strings.NewReader(""), &stdout, &bytes.Buffer{}, nil)
+	if err != nil {
+		t.Fatalf("Failed to start shell session: %v", err)
+	}
+	defer session.close()
+
+	if err := session.run(context.Background(), "export RR_NAME=captured"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	output, err := session.capture(context.Background(), `echo "$RR_NAME"; echo second`)
+	if err != nil {
+		t.Fatalf("Expected capture to succeed, got %v", err)
+	}
+	if output != "captured\nsecond" {
+		t.Errorf("Expected captured output without trailing newline, got %q", output)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("Expected captured output not to be streamed, got %q", stdout.String())
+	}
+
+	if _, err := session.capture(context.Background(), "false"); err == nil {
+		t.Error("Expected error when the captured command fails")
+	}
+}
+
+func TestShellSession_CancelKillsProcessGroup(t *testing.T) {
+	marker := filepath.Join(t.TempDir(), "marker")
+
+	session, err := startShellSession("sh", "", strings.NewReader(""), &bytes.Buffer{}, &bytes.Buffer{}, nil)
+	if err != nil {
+		t.Fatalf("Failed to start shell session: %v", err)
+	}
+	defer session.close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+
+	err = session.run(ctx, "(sleep 1; touch "+shellQuote(marker)+") & wait")
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Expected the command to be stopped by the deadline, got %v", err)
+	}
+	if err := session.run(context.Background(), "true"); err == nil {
+		t.Error("Expected the session to be unusable after its shell was killed")
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+	if _, err := os.Stat(marker); err == nil {
+		t.Error("Expected processes started by the command to be killed too")
+	}
+}
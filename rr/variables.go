@@ -0,0 +1,79 @@
+package rr
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// mergeVariables combines layers of variables into a single map.
+// Layers are given lowest precedence first, so later layers override earlier ones.
+func mergeVariables(layers ...map[string]string) map[string]string {
+	mergedVars := make(map[string]string)
+	for _, layer := range layers {
+		for k, v := range layer {
+			mergedVars[k] = v
+		}
+	}
+	return mergedVars
+}
+
+// substituteVariables replaces variable references (#var-name) with their values
+func substituteVariables(cmd string, variables map[string]string) string {
+	varUsageRegex := regexp.MustCompile(`#([a-zA-Z0-9_-]+)`)
+
+	return varUsageRegex.ReplaceAllStringFunc(cmd, func(match string) string {
+		varName := strings.TrimPrefix(match, "#")
+		if value, exists := variables[varName]; exists {
+			return value
+		}
+		return match
+	})
+}
+
+// usedGlobals returns the global variables, without prompts, that the commands or attributes of a
+// block refer to. Variables of the block itself take precedence over globals and are left out.
+func usedGlobals(block Block, globals map[string]string) map[string]string {
+	used := make(map[string]string)
+	for _, varName := range VariableReferences(block) {
+		if _, overridden := block.Variables[varName]; overridden {
+			continue
+		}
+		if value, exists := globals[varName]; exists && !strings.HasPrefix(value, "#PROMPT:") {
+			used[varName] = value
+		}
+	}
+	return used
+}
+
+// VariableReferences returns the names of the variables a block refers to with #name in its commands
+// and attributes, in the order they first appear. Whether they are defined isn't checked.
+func VariableReferences(block Block) []string {
+	var texts []string
+	for _, cmd := range block.Commands {
+		if _, captureCmd, isCapture := ParseCapture(cmd); isCapture {
+			cmd = captureCmd
+		}
+		texts = append(texts, cmd)
+	}
+	var attrKeys []string
+	for k := range block.Attributes {
+		attrKeys = append(attrKeys, k)
+	}
+	sort.Strings(attrKeys)
+	for _, k := range attrKeys {
+		texts = append(texts, block.Attributes[k])
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, text := range texts {
+		for _, varName := range unresolvedVariables(text) {
+			if !seen[varName] {
+				seen[varName] = true
+				names = append(names, varName)
+			}
+		}
+	}
+	return names
+}
@@ -0,0 +1,134 @@
+package rr
+
+import (
+	"testing"
+)
+
+func TestSubstituteVariables(t *testing.T) {
+	cmd := "echo #my-var and #another-var"
+	variables := map[string]string{
+		"my-var":      "value1",
+		"another-var": "value2",
+	}
+
+	result := substituteVariables(cmd, variables)
+	expected := "echo value1 and value2"
+
+	if result != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, result)
+	}
+}
+
+func TestSubstituteVariables_UnknownVariable(t *testing.T) {
+	cmd := "echo #unknown-var"
+	variables := map[string]string{}
+
+	result := substituteVariables(cmd, variables)
+	expected := "echo #unknown-var"
+
+	if result != expected {
+		t.Errorf("Expected unknown variable to remain unchanged, got '%s'", result)
+	}
+}
+
+func TestSubstituteVariables_MultipleOccurrences(t *testing.T) {
+	cmd := "echo #var and #var again"
+	variables := map[string]string{
+		"var": "test",
+	}
+
+	result := substituteVariables(cmd, variables)
+	expected := "echo test and test again"
+
+	if result != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, result)
+	}
+}
+
+func TestSubstituteVariables_WithPrompts(t *testing.T) {
+	cmd := "echo Hello #name, your age is #age"
+	variables := map[string]string{
+		"name": "John",
+		"age":  "30",
+	}
+
+	result := substituteVariables(cmd, variables)
+	expected := "echo Hello John, your age is 30"
+
+	if result != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, result)
+	}
+}
+
+func TestSubstituteVariables_NoVariables(t *testing.T) {
+	cmd := "echo Hello World"
+	variables := map[string]string{}
+
+	result := substituteVariables(cmd, variables)
+
+	if result != cmd {
+		t.Errorf("Expected command to remain unchanged, got '%s'", result)
+	}
+}
+
+func TestSubstituteVariables_WithEnvVars(t *testing.T) {
+	cmd := "echo App: #APP_NAME, Key: #API_KEY"
+	variables := map[string]string{
+		"APP_NAME": "MyApp",
+		"API_KEY":  "secret-123",
+	}
+
+	result := substituteVariables(cmd, variables)
+	expected := "echo App: MyApp, Key: secret-123"
+
+	if result != expected {
+		t.Errorf("Expected '%s', got '%s'", expected, result)
+	}
+}
+
+func TestSubstituteVariables_EnvVarPrecedence(t *testing.T) {
+	// Test that block variables override env variables
+	cmd := "echo Value: #TEST_VAR"
+
+	// Simulate merged variables (env vars first, then block vars)
+	mergedVars := map[string]string{
+		"TEST_VAR": "block-value", // Block var should override env var
+	}
+
+	result := substituteVariables(cmd, mergedVars)
+	expected := "echo Value: block-value"
+
+	if result != expected {
+		t.Errorf("Expected block variable to be used, got '%s'", result)
+	}
+}
+
+func TestMergeVariables_Precedence(t *testing.T) {
+	envVars := map[string]string{"A": "env", "B": "env", "C": "env"}
+	runVars := map[string]string{"B": "run", "C": "run"}
+	blockVars := map[string]string{"C": "block"}
+
+	merged := mergeVariables(envVars, runVars, blockVars)
+
+	if merged["A"] != "env" || merged["B"] != "run" || merged["C"] != "block" {
+		t.Errorf("Unexpected precedence: %v", merged)
+	}
+}
+
+func TestVariableReferences(t *testing.T) {
+	block := Block{
+		Attributes: map[string]string{"unless": "test -e #out", "cwd": "#dir"},
+		Commands:   []string{"echo #greeting #name", "token = #capture(login #name #user)"},
+	}
+
+	names := VariableReferences(block)
+	expected := []string{"greeting", "name", "user", "dir", "out"}
+	if len(names) != len(expected) {
+		t.Fatalf("Expected %v, got %v", expected, names)
+	}
+	for i := range expected {
+		if names[i] != expected[i] {
+			t.Fatalf("Expected %v, got %v", expected, names)
+		}
+	}
+}
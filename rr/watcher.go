@@ -0,0 +1,100 @@
+package rr
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"os/exec"
+	"sync"
+)
+
+// interruptWatcherScript reports every SIGINT it gets with an "int" line, and answers every line
+// it reads with "pong", after the interrupts that came before it. A read that a signal interrupts
+// fails as it does at the end of the input, which the trap tells apart.
+const interruptWatcherScript = `trap 'echo int; i=1' INT
+while :; do
+	if read -r line; then echo pong; elif [ -n "$i" ]; then i=; else exit 0; fi
+done`
+
+// interruptWatcher notices Ctrl-C at the terminal while a shell session has it. The terminal sends
+// SIGINT to its foreground process group, which is the session's rather than ours, so a small shell
+// joins that group and reports each SIGINT it gets.
+type interruptWatcher struct {
+	cmd   *exec.Cmd
+	ping  io.WriteCloser
+	pongs chan struct{}
+	done  chan struct{}
+
+	mu      sync.Mutex
+	handler func()
+}
+
+// startInterruptWatcher starts a watcher in the process group led by the given process
+func startInterruptWatcher(group *os.Process) (*interruptWatcher, error) {
+	cmd := exec.Command("sh", "-c", interruptWatcherScript)
+	joinProcessGroup(cmd, group)
+	ping, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	reports, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	w := &interruptWatcher{cmd: cmd, ping: ping, pongs: make(chan struct{}, 1), done: make(chan struct{})}
+	go w.read(reports)
+	return w, nil
+}
+
+// read passes the watcher's reports on until it exits
+func (w *interruptWatcher) read(reports io.Reader) {
+	defer close(w.done)
+
+	scanner := bufio.NewScanner(reports)
+	for scanner.Scan() {
+		switch scanner.Text() {
+		case "int":
+			w.mu.Lock()
+			handler := w.handler
+			w.mu.Unlock()
+			if handler != nil {
+				handler()
+			}
+		case "pong":
+			select {
+			case w.pongs <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// setHandler sets the function called for every SIGINT the watcher gets
+func (w *interruptWatcher) setHandler(handler func()) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.handler = handler
+}
+
+// sync waits until the handler has been called for every SIGINT the watcher got so far, or the
+// watcher is gone, e.g. because the process group was killed
+func (w *interruptWatcher) sync() {
+	if _, err := io.WriteString(w.ping, "ping\n"); err != nil {
+		return
+	}
+	select {
+	case <-w.pongs:
+	case <-w.done:
+	}
+}
+
+// stop ends the watcher and waits for it to exit
+func (w *interruptWatcher) stop() {
+	w.ping.Close()
+	<-w.done
+	w.cmd.Wait()
+}
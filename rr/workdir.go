@@ -0,0 +1,35 @@
+package rr
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// blockDir returns the directory the commands of a block run in: the project directory, or the
+// directory named by the block's cwd attribute, relative to the project directory unless it's absolute.
+// An empty result means the current directory.
+func blockDir(block Block, opts Options, variables map[string]string) (string, error) {
+	cwd, exists := block.Attributes["cwd"]
+	if !exists {
+		return opts.Dir, nil
+	}
+
+	dir := resolvePath(opts.Dir, substituteVariables(cwd, variables))
+	info, err := os.Stat(dir)
+	if err != nil {
+		return "", fmt.Errorf("working directory %s doesn't exist", dir)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("working directory %s isn't a directory", dir)
+	}
+	return dir, nil
+}
+
+// resolvePath makes a relative path relative to dir instead of the current directory
+func resolvePath(dir, path string) string {
+	if dir == "" || path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
@@ -0,0 +1,80 @@
+package rr
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBlockDir(t *testing.T) {
+	tempDir := t.TempDir()
+	os.Mkdir(filepath.Join(tempDir, "web"), 0755)
+	os.WriteFile(filepath.Join(tempDir, "file"), nil, 0644)
+	opts := Options{Dir: tempDir}
+
+	tests := []struct {
+		cwd      string
+		expected string
+		fails    bool
+	}{
+		{"", tempDir, false},
+		{"web", filepath.Join(tempDir, "web"), false},
+		{"#app", filepath.Join(tempDir, "web"), false},
+		{tempDir, tempDir, false},
+		{"missing", "", true},
+		{"file", "", true},
+	}
+
+	for _, tt := range tests {
+		block := Block{Attributes: map[string]string{}}
+		if tt.cwd != "" {
+			block.Attributes["cwd"] = tt.cwd
+		}
+		dir, err := blockDir(block, opts, map[string]string{"app": "web"})
+		if (err != nil) != tt.fails || dir != tt.expected {
+			t.Errorf("blockDir(cwd=%q) = (%q, %v), expected %q", tt.cwd, dir, err, tt.expected)
+		}
+	}
+}
+
+func TestRun_CwdAttribute(t *testing.T) {
+	tempDir := t.TempDir()
+	webDir := filepath.Join(tempDir, "web")
+	os.Mkdir(webDir, 0755)
+	os.WriteFile(filepath.Join(webDir, "built"), nil, 0644)
+	doc := Parse("<!-- RR[Where]{cwd=web}\npwd\n-->\n" +
+		"<!-- RR[Build]{cwd=web creates=built}\nfalse\n-->\n" +
+		"<!-- RR[Root]\npwd\n-->")
+
+	var stdout bytes.Buffer
+	runner := NewRunner(Options{Stdout: &stdout, Trust: true, Dir: tempDir})
+	if err := runner.Run(context.Background(), doc); err != nil {
+		t.Fatalf("Expected success, got %v", err)
+	}
+
+	// The temp dir may be reached through a symlink, e.g. on macOS
+	realDir, _ := filepath.EvalSymlinks(tempDir)
+	output := stdout.String()
+	if !strings.Contains(output, realDir+"/web\n") && !strings.Contains(output, webDir+"\n") {
+		t.Errorf("Expected the block to run in the web directory, got %q", output)
+	}
+	if statuses := resultStatuses(runner); statuses[1] != StatusSatisfied {
+		t.Errorf("Expected creates to be relative to the block's directory, got %v", statuses)
+	}
+	if !strings.Contains(output, realDir+"\n") && !strings.Contains(output, tempDir+"\n") {
+		t.Errorf("Expected blocks without cwd to run in the project directory, got %q", output)
+	}
+}
+
+func TestRun_MissingCwd(t *testing.T) {
+	doc := Parse("<!-- RR[Missing]{cwd=missing}\ntrue\n-->")
+
+	runner := NewRunner(Options{Stdout: &bytes.Buffer{}, Trust: true, Dir: t.TempDir()})
+	err := runner.Run(context.Background(), doc)
+	if err == nil || !strings.Contains(err.Error(), "working directory") {
+		t.Errorf("Expected a missing working directory to fail the block, got %v", err)
+	}
+}